@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/iamseth/oracledb_exporter/collector"
+)
+
+// checkConfig validates the default and custom metrics files of the
+// configuration without connecting to the database, writing the problems
+// found to out. Unknown keys are errors in strict mode, and warnings
+// otherwise. It returns false if any error was found.
+func checkConfig(out io.Writer, config *collector.Config, strict bool) bool {
+	var files []string
+	if config.DefaultMetricsFile != "" {
+		files = append(files, config.DefaultMetricsFile)
+	}
+	customFiles, err := config.CustomMetricsFiles()
+	if err != nil {
+		fmt.Fprintf(out, "%v\n", err)
+		return false
+	}
+	files = append(files, customFiles...)
+
+	valid := true
+	report := func(prefix string, err error) {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(out, "%s: %s\n", prefix, line)
+		}
+		valid = false
+	}
+
+	var all collector.Metrics
+	for _, file := range files {
+		metrics, unknown, err := collector.LoadMetricsFile(file)
+		if err != nil {
+			report(file, err)
+			continue
+		}
+		fileValid := true
+		for _, key := range unknown {
+			if strict {
+				fmt.Fprintf(out, "%s\n", key)
+				valid, fileValid = false, false
+			} else {
+				fmt.Fprintf(out, "warning: %s\n", key)
+			}
+		}
+		for _, metric := range metrics.Metric {
+			if err := metric.Validate(); err != nil {
+				report(fmt.Sprintf("%s: metric %s", file, metric.Context), err)
+				fileValid = false
+			}
+		}
+		if fileValid {
+			fmt.Fprintf(out, "%s: %d metrics OK\n", file, len(metrics.Metric))
+		}
+		all.Metric = append(all.Metric, metrics.Metric...)
+	}
+	if err := collector.CheckConsistency(all); err != nil {
+		report("consistency", err)
+	}
+	return valid
+}
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ExporterConfig is the content of the exporter configuration file
+type ExporterConfig struct {
+	AuthModules map[string]AuthModule `json:"auth_modules"`
+}
+
+// AuthModule holds the credentials and connection options used to connect
+// to the targets of multi-target scrapes, so they don't have to be part of
+// the target itself.
+type AuthModule struct {
+	User         string            `json:"user"`
+	Password     string            `json:"password"`
+	PasswordFile string            `json:"password_file"`
+	Options      map[string]string `json:"options"`
+	Wallet       string            `json:"wallet"`
+}
+
+// LoadExporterConfig reads the exporter configuration from a yaml file
+func LoadExporterConfig(configFile string) (*ExporterConfig, error) {
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the exporter config %s: %w", configFile, err)
+	}
+	config := &ExporterConfig{}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal the exporter config %s: %w", configFile, err)
+	}
+	for name, module := range config.AuthModules {
+		if module.User == "" {
+			return nil, fmt.Errorf("auth module %s: user is missing", name)
+		}
+		if module.Password != "" && module.PasswordFile != "" {
+			return nil, fmt.Errorf("auth module %s: password and password_file are mutually exclusive", name)
+		}
+	}
+	return config, nil
+}
+
+// DSN returns the connection string to the given target (host[:port]/service)
+// using the credentials of the auth module.
+func (a AuthModule) DSN(target string) (string, error) {
+	target = strings.TrimPrefix(target, "oracle://")
+	if strings.Contains(target, "@") {
+		return "", errors.New("target must not contain credentials when using an auth module")
+	}
+	host, service, _ := strings.Cut(target, "/")
+	if host == "" {
+		return "", errors.New("target host is missing")
+	}
+
+	password := a.Password
+	if a.PasswordFile != "" {
+		content, err := os.ReadFile(a.PasswordFile)
+		if err != nil {
+			return "", fmt.Errorf("cannot read password file: %w", err)
+		}
+		password = strings.TrimSpace(string(content))
+	}
+
+	query := url.Values{}
+	for option, value := range a.Options {
+		query.Set(option, value)
+	}
+	if a.Wallet != "" {
+		query.Set("wallet", a.Wallet)
+	}
+	dsn := url.URL{
+		Scheme:   "oracle",
+		User:     url.UserPassword(a.User, password),
+		Host:     host,
+		Path:     "/" + service,
+		RawQuery: query.Encode(),
+	}
+	return dsn.String(), nil
+}
@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Backend runs the requests of the metrics against the database
+type Backend interface {
+	// Ping checks that the database can be reached
+	Ping(ctx context.Context) error
+	// Query runs the request and returns the names of its columns and the
+	// values of its rows
+	Query(ctx context.Context, request string) (columns []string, rows [][]string, err error)
+	// Describe returns the names of the columns returned by the request,
+	// without fetching any row
+	Describe(ctx context.Context, request string) ([]string, error)
+	// Close releases the connections to the database
+	Close() error
+}
+
+// oracleBackend runs the requests on an Oracle database through go-ora
+type oracleBackend struct {
+	db *sql.DB
+}
+
+// newOracleBackend opens a pool of connections to the database. The
+// connections are only established when running requests.
+func newOracleBackend(dsn string, maxIdleConns, maxOpenConns int) (*oracleBackend, error) {
+	db, err := sql.Open("oracle", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetMaxOpenConns(maxOpenConns)
+	return &oracleBackend{db: db}, nil
+}
+
+func (b *oracleBackend) Ping(ctx context.Context) error {
+	return b.db.PingContext(ctx)
+}
+
+// inspired by https://kylewbanks.com/blog/query-result-to-map-in-golang
+func (b *oracleBackend) Query(ctx context.Context, request string) ([]string, [][]string, error) {
+	rows, err := b.db.QueryContext(ctx, request)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var values [][]string
+	for rows.Next() {
+		// Create a slice of interface{}'s to represent each column,
+		// and a second slice to contain pointers to each item in the columns slice.
+		row := make([]interface{}, len(columns))
+		rowPointers := make([]interface{}, len(columns))
+		for i := range row {
+			rowPointers[i] = &row[i]
+		}
+		if err := rows.Scan(rowPointers...); err != nil {
+			return nil, nil, err
+		}
+		rowValues := make([]string, len(columns))
+		for i := range row {
+			rowValues[i] = fmt.Sprintf("%v", row[i])
+		}
+		values = append(values, rowValues)
+	}
+	return columns, values, rows.Err()
+}
+
+func (b *oracleBackend) Describe(ctx context.Context, request string) ([]string, error) {
+	// The request may end with a comment, hence the line breaks
+	rows, err := b.db.QueryContext(ctx, "SELECT * FROM (\n"+request+"\n) WHERE 1=0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+func (b *oracleBackend) Close() error {
+	return b.db.Close()
+}
+
+// lowerColumns returns the names of the columns in lower case, as used by
+// the metrics
+func lowerColumns(columns []string) []string {
+	lowered := make([]string, len(columns))
+	for i, column := range columns {
+		lowered[i] = strings.ToLower(column)
+	}
+	return lowered
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"github.com/go-kit/log/level"
+)
+
+// breakerState tracks the consecutive failures of a metric
+type breakerState struct {
+	failures int
+	// skip is the number of scrapes during which the metric is not queried
+	skip int
+}
+
+// breakerAllows tells whether the metric should be queried during this
+// scrape. A metric whose breaker is open is skipped for a number of scrapes
+// growing exponentially with its consecutive failures, then queried again
+// to probe whether it recovered.
+func (e *Exporter) breakerAllows(metric Metric) bool {
+	if e.config.BreakerThreshold <= 0 {
+		return true
+	}
+	e.breakerMu.Lock()
+	defer e.breakerMu.Unlock()
+
+	state, ok := e.breakers[metricKey(metric)]
+	if !ok || state.skip == 0 {
+		return true
+	}
+	state.skip--
+	level.Debug(e.logger).Log("msg", "Circuit breaker open, skipping metric", "context", metric.Context, "remaining", state.skip)
+	return false
+}
+
+// breakerRecord updates the breaker of the metric with the result of its scrape
+func (e *Exporter) breakerRecord(metric Metric, err error) {
+	if e.config.BreakerThreshold <= 0 {
+		return
+	}
+	e.breakerMu.Lock()
+	defer e.breakerMu.Unlock()
+
+	key := metricKey(metric)
+	if err == nil {
+		if _, ok := e.breakers[key]; ok {
+			level.Info(e.logger).Log("msg", "Metric recovered, closing circuit breaker", "context", metric.Context)
+			delete(e.breakers, key)
+		}
+		e.breakerOpen.WithLabelValues(metric.Context).Set(0)
+		return
+	}
+
+	state, ok := e.breakers[key]
+	if !ok {
+		state = &breakerState{}
+		e.breakers[key] = state
+	}
+	state.failures++
+	if state.failures < e.config.BreakerThreshold {
+		return
+	}
+	state.skip = 1 << min(state.failures-e.config.BreakerThreshold, 30)
+	if e.config.BreakerMaxBackoff > 0 && state.skip > e.config.BreakerMaxBackoff {
+		state.skip = e.config.BreakerMaxBackoff
+	}
+	level.Warn(e.logger).Log("msg", "Metric failed repeatedly, opening circuit breaker", "context", metric.Context, "failures", state.failures, "skippedScrapes", state.skip)
+	e.breakerOpen.WithLabelValues(metric.Context).Set(1)
+}
+
+// resetBreakers closes the breakers of all metrics
+func (e *Exporter) resetBreakers() {
+	e.breakerMu.Lock()
+	e.breakers = make(map[string]*breakerState)
+	e.breakerMu.Unlock()
+	e.breakerOpen.Reset()
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerBacksOffExponentially(t *testing.T) {
+	e, _ := NewExporter(log.NewNopLogger(), &Config{BreakerThreshold: 2, BreakerMaxBackoff: 4})
+	metric := Metric{Context: "failing", Request: "SELECT value FROM missing_view"}
+	failure := errors.New("ORA-00942: table or view does not exist")
+
+	// skipped returns the number of scrapes skipped before the metric is
+	// queried again
+	skipped := func() int {
+		n := 0
+		for !e.breakerAllows(metric) {
+			n++
+		}
+		return n
+	}
+
+	e.breakerRecord(metric, failure)
+	assert.Equal(t, 0, skipped())
+	e.breakerRecord(metric, failure)
+	assert.Equal(t, 1, skipped())
+	assert.Equal(t, 1.0, testutil.ToFloat64(e.breakerOpen.WithLabelValues("failing")))
+	e.breakerRecord(metric, failure)
+	assert.Equal(t, 2, skipped())
+	e.breakerRecord(metric, failure)
+	assert.Equal(t, 4, skipped())
+	e.breakerRecord(metric, failure)
+	assert.Equal(t, 4, skipped())
+
+	e.breakerRecord(metric, nil)
+	assert.Equal(t, 0, skipped())
+	assert.Equal(t, 0.0, testutil.ToFloat64(e.breakerOpen.WithLabelValues("failing")))
+}
@@ -1,26 +1,21 @@
 package collector
 
 import (
-	"bytes"
 	"context"
-	"crypto/sha256"
-	"database/sql"
 	"errors"
 	"fmt"
-	"hash"
-	"io"
+	"io/fs"
 	"net/url"
-	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/BurntSushi/toml"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
-	"sigs.k8s.io/yaml"
+	"golang.org/x/sync/singleflight"
 )
 
 // Exporter collects Oracle DB metrics. It implements prometheus.Collector.
@@ -28,15 +23,52 @@ type Exporter struct {
 	config          *Config
 	mu              *sync.Mutex
 	metricsToScrape Metrics
+	// fileMetrics holds the metrics loaded from the metrics files, and
+	// runtimeMetrics those set through the runtime API, which replace the
+	// former with the same context
+	fileMetrics     Metrics
+	runtimeMetrics  []Metric
 	scrapeInterval  *time.Duration
 	dsn             string
 	duration, error prometheus.Gauge
 	totalScrapes    prometheus.Counter
 	scrapeErrors    *prometheus.CounterVec
-	scrapeResults   []prometheus.Metric
-	up              prometheus.Gauge
-	db              *sql.DB
-	logger          log.Logger
+	poolWait        prometheus.Histogram
+	// per context results of the last scrape
+	scrapeSuccess  *prometheus.GaugeVec
+	scrapeDuration *prometheus.GaugeVec
+	lastSuccess    *prometheus.GaugeVec
+	rowsReturned   *prometheus.GaugeVec
+	breakerOpen    *prometheus.GaugeVec
+	metricDisabled *prometheus.GaugeVec
+	// result of the last reload of the metrics files
+	configReloadSuccess     prometheus.Gauge
+	configReloadSuccessTime prometheus.Gauge
+	scrapeResults           []prometheus.Metric
+	scrapeGroup             singleflight.Group
+	scrapeCallers           scrapeCallers
+	lastScrape              time.Time
+	up                      prometheus.Gauge
+	backend                 Backend
+	logger                  log.Logger
+	// metricCache holds the results of the metrics having a scrape interval
+	metricCache map[string]cachedScrape
+	cacheMu     sync.Mutex
+	// breakers holds the circuit breakers of the failing metrics
+	breakers  map[string]*breakerState
+	breakerMu sync.Mutex
+	// missingColumns holds the columns missing from the result of the
+	// requests of the disabled metrics, checked once connected
+	missingColumns     map[string][]string
+	columnsChecked     bool
+	columnCheckRunning bool
+	// metricsVersion changes with the metrics to scrape, so that the results
+	// of the column check of the previous metrics are dropped
+	metricsVersion uint64
+	// recorder records the results of the requests
+	recorder *fixtureRecorder
+	// closed is set once the exporter is closed, to not reconnect
+	closed bool
 }
 
 // Config is the configuration of the exporter
@@ -47,6 +79,38 @@ type Config struct {
 	CustomMetrics      string
 	QueryTimeout       int
 	DefaultMetricsFile string
+	ScrapeConcurrency  int
+	ScrapeMinInterval  time.Duration
+	// BreakerThreshold is the number of consecutive failures after which a
+	// metric is skipped for a while, 0 disabling the circuit breaker.
+	BreakerThreshold int
+	// BreakerMaxBackoff is the maximum number of scrapes a failing metric is
+	// skipped for, 0 meaning no maximum.
+	BreakerMaxBackoff int
+	// StrictMetrics makes loading metrics files with unknown keys fail
+	// rather than only logging them.
+	StrictMetrics bool
+	// RecordFile is the file the columns and rows returned by the requests
+	// are recorded to.
+	RecordFile string
+	// ReplayFile is a file of recorded columns and rows, served instead of
+	// querying the database.
+	ReplayFile string
+}
+
+// MetricsPaths returns the default and custom metrics files, directories and
+// glob patterns of the configuration
+func (c *Config) MetricsPaths() []string {
+	var files []string
+	if c.DefaultMetricsFile != "" {
+		files = append(files, c.DefaultMetricsFile)
+	}
+	for _, customMetrics := range strings.Split(c.CustomMetrics, ",") {
+		if customMetrics != "" {
+			files = append(files, customMetrics)
+		}
+	}
+	return files
 }
 
 // CreateDefaultConfig returns the default configuration of the Exporter
@@ -71,6 +135,30 @@ type Metric struct {
 	FieldToAppend    string
 	Request          string
 	IgnoreZeroResult bool
+	ScrapeInterval   string
+	QueryTimeout     string
+	Priority         int
+
+	// columns and metricsLabels hold the column and labels of the metrics
+	// converted from the v2 format which differ from their name and Labels
+	columns       map[string]string
+	metricsLabels map[string][]string
+}
+
+// column returns the column holding the value of the metric
+func (m Metric) column(metric string) string {
+	if column, ok := m.columns[metric]; ok {
+		return column
+	}
+	return metric
+}
+
+// labels returns the labels of the metric
+func (m Metric) labels(metric string) []string {
+	if labels, ok := m.metricsLabels[metric]; ok {
+		return labels
+	}
+	return m.Labels
 }
 
 // Metrics is a container structure for prometheus metrics
@@ -78,11 +166,14 @@ type Metrics struct {
 	Metric []Metric `json:"metrics"`
 }
 
+const (
+	namespace    = "oracledb"
+	exporterName = "exporter"
+)
+
 var (
-	additionalMetrics Metrics
-	hashMap           = make(map[int][]byte)
-	namespace         = "oracledb"
-	exporterName      = "exporter"
+	// errQueryTimeout is returned when a query did not complete within its timeout
+	errQueryTimeout = errors.New("oracle query timed out")
 )
 
 func maskDsn(dsn string) string {
@@ -94,9 +185,32 @@ func maskDsn(dsn string) string {
 	return dsn
 }
 
-// NewExporter creates a new Exporter instance
+// NewExporter creates a new Exporter instance. The exporter keeps its own
+// copy of the configuration, and shares no state with the other exporters, so
+// that several exporters can be created concurrently and run side by side.
+// Unlike New, the default metrics are used when the metrics files cannot be
+// loaded, the database is not pinged, and the exporter is returned along with
+// the connection error, if any.
 func NewExporter(logger log.Logger, cfg *Config) (*Exporter, error) {
-	e := &Exporter{
+	return New(context.Background(), WithConfig(*cfg), WithLogger(logger), withFallback())
+}
+
+// newExporter returns an exporter with its metrics loaded, but without
+// backend
+func newExporter(logger log.Logger, cfg *Config) *Exporter {
+	e := buildExporter(logger, cfg)
+	if err := e.reloadMetrics(); err != nil {
+		level.Warn(e.logger).Log("msg", "proceeding to run with default metrics")
+		e.fileMetrics = e.DefaultMetrics()
+		e.metricsToScrape = e.fileMetrics
+	}
+	return e
+}
+
+// buildExporter returns an exporter without metrics nor backend
+func buildExporter(logger log.Logger, cfg *Config) *Exporter {
+	config := *cfg
+	return &Exporter{
 		mu:  &sync.Mutex{},
 		dsn: cfg.DSN,
 		duration: prometheus.NewGauge(prometheus.GaugeOpts{
@@ -116,24 +230,77 @@ func NewExporter(logger log.Logger, cfg *Config) (*Exporter, error) {
 			Subsystem: exporterName,
 			Name:      "scrape_errors_total",
 			Help:      "Total number of times an error occurred scraping a Oracle database.",
-		}, []string{"collector"}),
+		}, []string{"collector", "reason"}),
 		error: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Subsystem: exporterName,
 			Name:      "last_scrape_error",
 			Help:      "Whether the last scrape of metrics from Oracle DB resulted in an error (1 for error, 0 for success).",
 		}),
+		poolWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: exporterName,
+			Name:      "scrape_pool_wait_seconds",
+			Help:      "Time metrics waited for a free worker before being scraped.",
+		}),
+		scrapeSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporterName,
+			Name:      "scrape_success",
+			Help:      "Whether the last scrape of the metric context succeeded (1 for success, 0 for error).",
+		}, []string{"context"}),
+		scrapeDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporterName,
+			Name:      "scrape_duration_seconds",
+			Help:      "Duration of the last scrape of the metric context.",
+		}, []string{"context"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporterName,
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Timestamp of the last successful scrape of the metric context.",
+		}, []string{"context"}),
+		rowsReturned: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporterName,
+			Name:      "rows_returned",
+			Help:      "Number of rows returned by the request of the metric context during its last scrape.",
+		}, []string{"context"}),
+		breakerOpen: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporterName,
+			Name:      "circuit_breaker_open",
+			Help:      "Whether the metric context is skipped after repeated failures (1 for skipped, 0 for scraped).",
+		}, []string{"context"}),
+		metricDisabled: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporterName,
+			Name:      "metric_disabled",
+			Help:      "Whether the metric context is disabled because its request doesn't return the columns it uses.",
+		}, []string{"context"}),
+		configReloadSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporterName,
+			Name:      "config_last_reload_successful",
+			Help:      "Whether the last reload of the metrics files succeeded (1 for success, 0 for error).",
+		}),
+		configReloadSuccessTime: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: exporterName,
+			Name:      "config_last_reload_success_timestamp_seconds",
+			Help:      "Timestamp of the last successful reload of the metrics files.",
+		}),
 		up: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "up",
 			Help:      "Whether the Oracle database server is up.",
 		}),
-		logger: logger,
-		config: cfg,
+		logger:      logger,
+		config:      &config,
+		metricCache: make(map[string]cachedScrape),
+		breakers:    make(map[string]*breakerState),
 	}
-	e.metricsToScrape = e.DefaultMetrics()
-	err := e.connect()
-	return e, err
 }
 
 // Describe describes all the metrics exported by the Oracle DB exporter.
@@ -166,6 +333,15 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements prometheus.Collector.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.CollectContext(context.Background(), ch)
+}
+
+// CollectContext is like Collect, but the queries run against the database
+// are cancelled when ctx is done. Concurrent calls share a scrape, which runs
+// until the last of them is done: a call whose ctx is done returns without
+// metrics while the others wait, and otherwise sends the metrics scraped so
+// far.
+func (e *Exporter) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
 	// they are running scheduled scrapes we should only scrape new data
 	// on the interval
 	if e.scrapeInterval != nil && *e.scrapeInterval != 0 {
@@ -178,14 +354,102 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
-	// otherwise do a normal scrape per request
+	// otherwise do a normal scrape per request, shared by the concurrent
+	// requests and reused by the following ones for ScrapeMinInterval
+	for {
+		shared := e.scrapeCallers.join()
+		resultCh := e.scrapeGroup.DoChan("scrape", func() (interface{}, error) {
+			return e.sharedScrape(shared)
+		})
+		var result singleflight.Result
+		select {
+		case result = <-resultCh:
+			e.scrapeCallers.leave()
+			if result.Err != nil && ctx.Err() == nil {
+				// the scrape joined was cancelled by its previous callers
+				continue
+			}
+		case <-ctx.Done():
+			if !e.scrapeCallers.leave() {
+				return
+			}
+			result = <-resultCh
+		}
+		for _, r := range result.Val.([]prometheus.Metric) {
+			ch <- r
+		}
+		return
+	}
+}
+
+// sharedScrape scrapes the database, unless it was scraped less than
+// ScrapeMinInterval ago. The results of a scrape cut short by ctx are
+// returned along with its error, and are not reused.
+func (e *Exporter) sharedScrape(ctx context.Context) ([]prometheus.Metric, error) {
 	e.mu.Lock() // ensure no simultaneous scrapes
 	defer e.mu.Unlock()
-	e.scrape(ch)
+	if time.Since(e.lastScrape) < e.config.ScrapeMinInterval {
+		level.Debug(e.logger).Log("msg", "Serving results of previous scrape", "age", time.Since(e.lastScrape))
+		return e.scrapeResults, nil
+	}
+	begun := time.Now()
+	results := e.bufferedScrape(ctx)
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	e.lastScrape = begun
+	e.scrapeResults = results
+	return results, nil
+}
+
+// scrapeCallers counts the callers of CollectContext sharing a scrape, whose
+// context is cancelled once they all left
+type scrapeCallers struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	count  int
+}
+
+// join returns the context of the shared scrape
+func (c *scrapeCallers) join() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.count == 0 {
+		c.ctx, c.cancel = context.WithCancel(context.Background())
+	}
+	c.count++
+	return c.ctx
+}
+
+// leave reports whether the caller was the last one, in which case the
+// context of the shared scrape is cancelled
+func (c *scrapeCallers) leave() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count--
+	if c.count > 0 {
+		return false
+	}
+	c.cancel()
+	return true
+}
+
+// collectExporterMetrics sends the metrics describing the exporter itself
+func (e *Exporter) collectExporterMetrics(ch chan<- prometheus.Metric) {
 	ch <- e.duration
 	ch <- e.totalScrapes
 	ch <- e.error
 	e.scrapeErrors.Collect(ch)
+	ch <- e.poolWait
+	e.scrapeSuccess.Collect(ch)
+	e.scrapeDuration.Collect(ch)
+	e.lastSuccess.Collect(ch)
+	e.rowsReturned.Collect(ch)
+	e.breakerOpen.Collect(ch)
+	e.metricDisabled.Collect(ch)
+	ch <- e.configReloadSuccess
+	ch <- e.configReloadSuccessTime
 	ch <- e.up
 }
 
@@ -200,7 +464,7 @@ func (e *Exporter) RunScheduledScrapes(ctx context.Context, si time.Duration) {
 		select {
 		case <-ticker.C:
 			e.mu.Lock() // ensure no simultaneous scrapes
-			e.scheduledScrape()
+			e.scrapeResults = e.bufferedScrape(ctx)
 			e.mu.Unlock()
 		case <-ctx.Done():
 			return
@@ -208,37 +472,35 @@ func (e *Exporter) RunScheduledScrapes(ctx context.Context, si time.Duration) {
 	}
 }
 
-func (e *Exporter) scheduledScrape() {
+// bufferedScrape runs a scrape and returns its results
+func (e *Exporter) bufferedScrape(ctx context.Context) []prometheus.Metric {
 	metricCh := make(chan prometheus.Metric, 5)
+	results := []prometheus.Metric{}
 
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		e.scrapeResults = []prometheus.Metric{}
 		for {
 			scrapeResult, more := <-metricCh
 			if more {
-				e.scrapeResults = append(e.scrapeResults, scrapeResult)
+				results = append(results, scrapeResult)
 				continue
 			}
 			return
 		}
 	}()
-	e.scrape(metricCh)
+	e.scrape(ctx, metricCh)
 
 	// report metadata metrics
-	metricCh <- e.duration
-	metricCh <- e.totalScrapes
-	metricCh <- e.error
-	e.scrapeErrors.Collect(metricCh)
-	metricCh <- e.up
+	e.collectExporterMetrics(metricCh)
 
 	close(metricCh)
 	wg.Wait()
+	return results
 }
 
-func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
+func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric) {
 	e.totalScrapes.Inc()
 	var err error
 	var errmutex sync.Mutex
@@ -252,8 +514,8 @@ func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
 		}
 	}(time.Now())
 
-	if err = e.db.Ping(); err != nil {
-		if strings.Contains(err.Error(), "sql: database is closed") {
+	if err = e.backend.Ping(ctx); err != nil {
+		if strings.Contains(err.Error(), "sql: database is closed") && !e.closed {
 			level.Info(e.logger).Log("Reconnecting to DB")
 			err = e.connect()
 			if err != nil {
@@ -262,7 +524,7 @@ func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
 		}
 	}
 
-	if err = e.db.Ping(); err != nil {
+	if err = e.backend.Ping(ctx); err != nil {
 		level.Error(e.logger).Log("error pinging oracle:", err.Error())
 		e.up.Set(0)
 		return
@@ -271,65 +533,133 @@ func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
 	level.Debug(e.logger).Log("Successfully pinged Oracle database: ", maskDsn(e.dsn))
 	e.up.Set(1)
 
-	if e.checkIfMetricsChanged() {
-		e.reloadMetrics()
-	}
+	// The column check postponed while the database couldn't be reached
+	// runs in the background, the metrics being scraped meanwhile
+	e.startColumnCheck()
 
-	wg := sync.WaitGroup{}
+	// Metrics with the highest priority are handed to the workers first
+	metrics := make([]Metric, len(e.metricsToScrape.Metric))
+	copy(metrics, e.metricsToScrape.Metric)
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Priority > metrics[j].Priority
+	})
 
-	for _, metric := range e.metricsToScrape.Metric {
-		wg.Add(1)
-		metric := metric //https://golang.org/doc/faq#closures_and_goroutines
+	f := func(metric Metric) {
+		level.Debug(e.logger).Log("About to scrape metric: ")
+		level.Debug(e.logger).Log("- Metric MetricsDesc: ", fmt.Sprintf("%+v", metric.MetricsDesc))
+		level.Debug(e.logger).Log("- Metric Context: ", metric.Context)
+		level.Debug(e.logger).Log("- Metric MetricsType: ", fmt.Sprintf("%+v", metric.MetricsType))
+		level.Debug(e.logger).Log("- Metric MetricsBuckets: ", fmt.Sprintf("%+v", metric.MetricsBuckets), "(Ignored unless Histogram type)")
+		level.Debug(e.logger).Log("- Metric Labels: ", fmt.Sprintf("%+v", metric.Labels))
+		level.Debug(e.logger).Log("- Metric FieldToAppend: ", metric.FieldToAppend)
+		level.Debug(e.logger).Log("- Metric IgnoreZeroResult: ", fmt.Sprintf("%+v", metric.IgnoreZeroResult))
+		level.Debug(e.logger).Log("- Metric ScrapeInterval: ", metric.ScrapeInterval)
+		level.Debug(e.logger).Log("- Metric QueryTimeout: ", metric.QueryTimeout)
+		level.Debug(e.logger).Log("- Metric Request: ", metric.Request)
 
-		f := func() {
-			defer wg.Done()
+		if len(metric.Request) == 0 {
+			level.Error(e.logger).Log("Error scraping for ", metric.MetricsDesc, ". Did you forget to define request in your metrics config file?")
+			e.scrapeSuccess.WithLabelValues(metric.Context).Set(0)
+			return
+		}
 
-			level.Debug(e.logger).Log("About to scrape metric: ")
-			level.Debug(e.logger).Log("- Metric MetricsDesc: ", fmt.Sprintf("%+v", metric.MetricsDesc))
-			level.Debug(e.logger).Log("- Metric Context: ", metric.Context)
-			level.Debug(e.logger).Log("- Metric MetricsType: ", fmt.Sprintf("%+v", metric.MetricsType))
-			level.Debug(e.logger).Log("- Metric MetricsBuckets: ", fmt.Sprintf("%+v", metric.MetricsBuckets), "(Ignored unless Histogram type)")
-			level.Debug(e.logger).Log("- Metric Labels: ", fmt.Sprintf("%+v", metric.Labels))
-			level.Debug(e.logger).Log("- Metric FieldToAppend: ", metric.FieldToAppend)
-			level.Debug(e.logger).Log("- Metric IgnoreZeroResult: ", fmt.Sprintf("%+v", metric.IgnoreZeroResult))
-			level.Debug(e.logger).Log("- Metric Request: ", metric.Request)
-
-			if len(metric.Request) == 0 {
-				level.Error(e.logger).Log("Error scraping for ", metric.MetricsDesc, ". Did you forget to define request in your metrics config file?")
-				return
-			}
+		if len(metric.MetricsDesc) == 0 {
+			level.Error(e.logger).Log("Error scraping for query", metric.Request, ". Did you forget to define metricsdesc in your metrics config file?")
+			e.scrapeSuccess.WithLabelValues(metric.Context).Set(0)
+			return
+		}
 
-			if len(metric.MetricsDesc) == 0 {
-				level.Error(e.logger).Log("Error scraping for query", metric.Request, ". Did you forget to define metricsdesc in your metrics config file?")
-				return
+		for column, metricType := range metric.MetricsType {
+			if metricType == "histogram" {
+				_, ok := metric.MetricsBuckets[column]
+				if !ok {
+					level.Error(e.logger).Log("Unable to find MetricsBuckets configuration key for metric. (metric=" + column + ")")
+					e.scrapeSuccess.WithLabelValues(metric.Context).Set(0)
+					return
+				}
 			}
+		}
 
-			for column, metricType := range metric.MetricsType {
-				if metricType == "histogram" {
-					_, ok := metric.MetricsBuckets[column]
-					if !ok {
-						level.Error(e.logger).Log("Unable to find MetricsBuckets configuration key for metric. (metric=" + column + ")")
-						return
-					}
-				}
+		if missing, ok := e.missingColumns[metricKey(metric)]; ok {
+			level.Debug(e.logger).Log("msg", "Skipping disabled metric", "context", metric.Context, "missing", strings.Join(missing, ","))
+			e.scrapeSuccess.WithLabelValues(metric.Context).Set(0)
+			return
+		}
+
+		if !e.breakerAllows(metric) {
+			return
+		}
+
+		scrapeStart := time.Now()
+		cached, err1 := e.scrapeMetric(ctx, ch, metric)
+		// Cached results keep the status and the timestamp of the scrape
+		// which produced them
+		if cached {
+			return
+		}
+		// Failures caused by the cancellation of the scrape are not the
+		// metric's fault
+		if ctx.Err() == nil {
+			e.breakerRecord(metric, err1)
+		}
+		e.scrapeDuration.WithLabelValues(metric.Context).Set(time.Since(scrapeStart).Seconds())
+		if err1 != nil {
+			errmutex.Lock()
+			{
+				err = err1
+			}
+			errmutex.Unlock()
+			level.Error(e.logger).Log("scrapeMetricContext", metric.Context, "ScrapeDuration", time.Since(scrapeStart), "msg", err1.Error())
+			reason := "error"
+			if errors.Is(err1, errQueryTimeout) {
+				reason = "timeout"
 			}
+			e.scrapeErrors.WithLabelValues(metric.Context, reason).Inc()
+			e.scrapeSuccess.WithLabelValues(metric.Context).Set(0)
+		} else {
+			level.Debug(e.logger).Log("successfully scraped metric: ", metric.Context, metric.MetricsDesc, time.Since(scrapeStart))
+			e.scrapeSuccess.WithLabelValues(metric.Context).Set(1)
+			e.lastSuccess.WithLabelValues(metric.Context).SetToCurrentTime()
+		}
+	}
 
-			scrapeStart := time.Now()
-			if err1 := e.ScrapeMetric(e.db, ch, metric); err1 != nil {
-				errmutex.Lock()
-				{
-					err = err1
-				}
-				errmutex.Unlock()
-				level.Error(e.logger).Log("scrapeMetricContext", metric.Context, "ScrapeDuration", time.Since(scrapeStart), "msg", err1.Error())
-				e.scrapeErrors.WithLabelValues(metric.Context).Inc()
-			} else {
-				level.Debug(e.logger).Log("successfully scraped metric: ", metric.Context, metric.MetricsDesc, time.Since(scrapeStart))
+	// Without concurrency limit, every metric gets its own worker
+	workers := e.config.ScrapeConcurrency
+	if workers <= 0 || workers > len(metrics) {
+		workers = len(metrics)
+	}
+	queue := make(chan Metric)
+	queued := time.Now()
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for metric := range queue {
+				e.poolWait.Observe(time.Since(queued).Seconds())
+				f(metric)
 			}
+		}()
+	}
+	// Metrics which have not been handed to a worker yet are skipped once
+	// the scrape is cancelled
+dispatch:
+	for _, metric := range metrics {
+		select {
+		case queue <- metric:
+		case <-ctx.Done():
+			level.Warn(e.logger).Log("msg", "Scrape cancelled, skipping remaining metrics", "error", ctx.Err())
+			break dispatch
 		}
-		go f()
 	}
+	close(queue)
 	wg.Wait()
+
+	if e.recorder != nil {
+		if err := e.recorder.save(); err != nil {
+			level.Error(e.logger).Log("msg", "Unable to save recorded fixtures", "error", err)
+		}
+	}
 }
 
 func (e *Exporter) connect() error {
@@ -339,125 +669,225 @@ func (e *Exporter) connect() error {
 		return err
 	}
 	level.Debug(e.logger).Log("launching connection: ", maskDsn(e.dsn))
-	db, err := sql.Open("oracle", e.dsn)
+	level.Debug(e.logger).Log("set max idle connections to ", e.config.MaxIdleConns)
+	level.Debug(e.logger).Log("set max open connections to ", e.config.MaxOpenConns)
+	backend, err := newOracleBackend(e.dsn, e.config.MaxIdleConns, e.config.MaxOpenConns)
 	if err != nil {
-		level.Error(e.logger).Log("error while connecting to", e.dsn)
+		level.Error(e.logger).Log("error while connecting to", maskDsn(e.dsn))
 		return err
 	}
-	level.Debug(e.logger).Log("set max idle connections to ", e.config.MaxIdleConns)
-	db.SetMaxIdleConns(e.config.MaxIdleConns)
-	level.Debug(e.logger).Log("set max open connections to ", e.config.MaxOpenConns)
-	db.SetMaxOpenConns(e.config.MaxOpenConns)
 	level.Debug(e.logger).Log("successfully connected to: ", maskDsn(e.dsn))
-	e.db = db
+	e.setBackend(backend)
 	return nil
 }
 
-func (e *Exporter) checkIfMetricsChanged() bool {
-	for i, _customMetrics := range strings.Split(e.config.CustomMetrics, ",") {
-		if len(_customMetrics) == 0 {
-			continue
-		}
-		level.Debug(e.logger).Log("checking modifications in following metrics definition file:", _customMetrics)
-		h := sha256.New()
-		if err := hashFile(h, _customMetrics); err != nil {
-			level.Error(e.logger).Log("unable to get file hash", err.Error())
-			return false
-		}
-		// If any of files has been changed reload metrics
-		if !bytes.Equal(hashMap[i], h.Sum(nil)) {
-			level.Info(e.logger).Log(_customMetrics, "has been changed. Reloading metrics...")
-			hashMap[i] = h.Sum(nil)
-			return true
-		}
+// open sets the backend of the exporter: the given one if any, otherwise the
+// fixtures of the replay file, or a connection to the database
+func (e *Exporter) open(backend Backend) error {
+	if e.config.RecordFile != "" {
+		e.recorder = newFixtureRecorder(e.config.RecordFile)
+	}
+	var err error
+	switch {
+	case backend != nil:
+		e.setBackend(backend)
+	case e.config.ReplayFile != "":
+		var fixtures []Fixture
+		fixtures, err = LoadFixtures(e.config.ReplayFile)
+		e.backend = NewFakeBackend(fixtures...)
+	default:
+		err = e.connect()
+	}
+	if err == nil {
+		e.startColumnCheck()
 	}
-	return false
+	return err
 }
 
-func hashFile(h hash.Hash, fn string) error {
-	f, err := os.Open(fn)
-	if err != nil {
-		return err
+// setBackend sets the backend of the exporter, recording its results if
+// requested
+func (e *Exporter) setBackend(backend Backend) {
+	e.backend = backend
+	if e.recorder != nil {
+		e.backend = recordingBackend{Backend: backend, recorder: e.recorder}
 	}
-	defer f.Close()
-	if _, err := io.Copy(h, f); err != nil {
+}
+
+// Reload reloads the default and custom metrics files. The metrics in use
+// are only replaced when all the files could be loaded, otherwise they are
+// kept and the error is returned.
+func (e *Exporter) Reload() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.reloadMetrics()
+}
+
+func (e *Exporter) reloadMetrics() error {
+	metrics, err := e.loadMetrics()
+	if err != nil {
+		level.Error(e.logger).Log("msg", "Unable to reload metrics, keeping the previous ones", "error", err)
+		e.configReloadSuccess.Set(0)
 		return err
 	}
+
+	e.fileMetrics = metrics
+	e.useMetrics(mergeMetrics(e.fileMetrics, e.runtimeMetrics))
+	e.configReloadSuccess.Set(1)
+	e.configReloadSuccessTime.SetToCurrentTime()
 	return nil
 }
 
-func (e *Exporter) reloadMetrics() {
-	// Truncate metricsToScrape
-	e.metricsToScrape.Metric = []Metric{}
+// useMetrics replaces the metrics to scrape
+func (e *Exporter) useMetrics(metrics Metrics) {
+	// Results of removed or modified metrics must not be served anymore
+	e.resetMetricCache()
+	e.scrapeSuccess.Reset()
+	e.scrapeDuration.Reset()
+	e.lastSuccess.Reset()
+	e.rowsReturned.Reset()
+	e.resetBreakers()
+	e.metricDisabled.Reset()
+	e.missingColumns = nil
+	e.columnsChecked = false
+	e.columnCheckRunning = false
+	e.metricsVersion++
 
-	// Load default metrics
-	defaultMetrics := e.DefaultMetrics()
-	e.metricsToScrape.Metric = defaultMetrics.Metric
+	e.metricsToScrape = metrics
+	if e.backend != nil {
+		e.startColumnCheck()
+	}
+}
+
+// loadMetrics loads the default and custom metrics files into a new Metrics
+func (e *Exporter) loadMetrics() (Metrics, error) {
+	var metrics Metrics
+
+	// Load default metrics. A default metrics file which cannot be read, such
+	// as the relative default one when run from another directory, falls back
+	// to the embedded metrics, while an invalid one fails the load.
+	if e.config.DefaultMetricsFile != "" {
+		var err error
+		var pathErr *fs.PathError
+		metrics, err = e.loadMetricsFile(e.config.DefaultMetricsFile)
+		switch {
+		case errors.As(err, &pathErr):
+			level.Warn(e.logger).Log("msg", "Unable to read the default metrics file, using the embedded default metrics", "file", e.config.DefaultMetricsFile, "error", err)
+			metrics = e.embeddedMetrics()
+		case err != nil:
+			return Metrics{}, err
+		}
+	} else {
+		metrics = e.embeddedMetrics()
+	}
 
 	// If custom metrics, load it
-	if strings.Compare(e.config.CustomMetrics, "") != 0 {
-		for _, _customMetrics := range strings.Split(e.config.CustomMetrics, ",") {
-			if strings.HasSuffix(_customMetrics, "toml") {
-				if err := loadTomlMetricsConfig(_customMetrics, &additionalMetrics); err != nil {
-					panic(err)
-				}
-			} else {
-				if err := loadYamlMetricsConfig(_customMetrics, &additionalMetrics); err != nil {
-					panic(err)
-				}
+	customMetricsFiles, err := e.config.CustomMetricsFiles()
+	if err != nil {
+		return Metrics{}, err
+	}
+	if len(customMetricsFiles) > 0 {
+		for _, _customMetrics := range customMetricsFiles {
+			additionalMetrics, err := e.loadMetricsFile(_customMetrics)
+			if err != nil {
+				return Metrics{}, err
 			}
 			level.Info(e.logger).Log("event", "Successfully loaded custom metrics from "+_customMetrics)
 			level.Debug(e.logger).Log("custom metrics parsed content", fmt.Sprintf("%+v", additionalMetrics))
-			e.metricsToScrape.Metric = append(e.metricsToScrape.Metric, additionalMetrics.Metric...)
+			metrics.Metric = append(metrics.Metric, additionalMetrics.Metric...)
 		}
 	} else {
 		level.Debug(e.logger).Log("No custom metrics defined.")
 	}
+	// Metrics which cannot be scraped at all are rejected with the files
+	if err := validateMetricTypes(metrics); err != nil {
+		return Metrics{}, err
+	}
+	return metrics, nil
 }
 
-func loadYamlMetricsConfig(_metricsFileName string, metrics *Metrics) error {
-	yamlBytes, err := os.ReadFile(_metricsFileName)
+// loadMetricsFile loads a metrics file, logging its unknown keys, which are
+// errors in strict mode
+func (e *Exporter) loadMetricsFile(metricsFile string) (Metrics, error) {
+	metrics, unknown, err := LoadMetricsFile(metricsFile)
 	if err != nil {
-		return fmt.Errorf("cannot read the metrics config %s: %w", _metricsFileName, err)
+		return Metrics{}, err
 	}
-	if err := yaml.Unmarshal(yamlBytes, metrics); err != nil {
-		return fmt.Errorf("cannot unmarshal the metrics config %s: %w", _metricsFileName, err)
+	if len(unknown) > 0 && e.config.StrictMetrics {
+		return Metrics{}, UnknownKeysError(unknown)
 	}
-	return nil
+	for _, key := range unknown {
+		level.Warn(e.logger).Log("msg", "Unknown key in metrics file", "file", key.File, "line", key.Line, "key", key.Key)
+	}
+	return metrics, nil
 }
 
-func loadTomlMetricsConfig(_customMetrics string, metrics *Metrics) error {
-	if _, err := toml.DecodeFile(_customMetrics, metrics); err != nil {
-		return fmt.Errorf("cannot read the metrics config %s: %w", _customMetrics, err)
+// loadMetricsConfig loads a toml or yaml metrics file, depending on its extension
+func loadMetricsConfig(_metricsFileName string, metrics *Metrics) error {
+	loaded, _, err := LoadMetricsFile(_metricsFileName)
+	if err != nil {
+		return err
 	}
+	*metrics = loaded
 	return nil
 }
 
 // ScrapeMetric is an interface method to call scrapeGenericValues using Metric struct values
-func (e *Exporter) ScrapeMetric(db *sql.DB, ch chan<- prometheus.Metric, metricDefinition Metric) error {
+func (e *Exporter) ScrapeMetric(backend Backend, ch chan<- prometheus.Metric, metricDefinition Metric) error {
+	return e.ScrapeMetricContext(context.Background(), backend, ch, metricDefinition)
+}
+
+// ScrapeMetricContext is like ScrapeMetric, but the query is cancelled when ctx is done
+func (e *Exporter) ScrapeMetricContext(ctx context.Context, backend Backend, ch chan<- prometheus.Metric, metricDefinition Metric) error {
 	level.Debug(e.logger).Log("calling function ScrapeGenericValues()")
-	return e.scrapeGenericValues(db, ch, metricDefinition.Context, metricDefinition.Labels,
+	return e.scrapeGenericValues(ctx, backend, ch, metricDefinition.Context, metricDefinition.Labels,
 		metricDefinition.MetricsDesc, metricDefinition.MetricsType, metricDefinition.MetricsBuckets,
-		metricDefinition.FieldToAppend, metricDefinition.IgnoreZeroResult,
-		metricDefinition.Request)
+		metricDefinition.columns, metricDefinition.metricsLabels, metricDefinition.FieldToAppend, metricDefinition.IgnoreZeroResult,
+		metricDefinition.Request, e.metricQueryTimeout(metricDefinition))
+}
+
+// metricQueryTimeout returns the timeout of the metric's request, which
+// defaults to the query timeout of the exporter
+func (e *Exporter) metricQueryTimeout(metric Metric) time.Duration {
+	defaultTimeout := time.Duration(e.config.QueryTimeout) * time.Second
+	if metric.QueryTimeout == "" {
+		return defaultTimeout
+	}
+	timeout, err := time.ParseDuration(metric.QueryTimeout)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "Unable to parse querytimeout, using default query timeout", "context", metric.Context, "querytimeout", metric.QueryTimeout, "error", err)
+		return defaultTimeout
+	}
+	return timeout
 }
 
 // generic method for retrieving metrics.
-func (e *Exporter) scrapeGenericValues(db *sql.DB, ch chan<- prometheus.Metric, context string, labels []string,
-	metricsDesc map[string]string, metricsType map[string]string, metricsBuckets map[string]map[string]string, fieldToAppend string, ignoreZeroResult bool, request string) error {
+func (e *Exporter) scrapeGenericValues(ctx context.Context, backend Backend, ch chan<- prometheus.Metric, context string, labels []string,
+	metricsDesc map[string]string, metricsType map[string]string, metricsBuckets map[string]map[string]string,
+	columns map[string]string, metricsLabels map[string][]string, fieldToAppend string, ignoreZeroResult bool, request string,
+	queryTimeout time.Duration) error {
 	metricsCount := 0
+	rowsCount := 0
 	genericParser := func(row map[string]string) error {
-		// Construct labels value
-		labelsValues := []string{}
-		for _, label := range labels {
-			labelsValues = append(labelsValues, row[label])
-		}
+		rowsCount++
 		// Construct Prometheus values to sent back
 		for metric, metricHelp := range metricsDesc {
-			value, err := strconv.ParseFloat(strings.TrimSpace(row[metric]), 64)
+			column := metric
+			if c, ok := columns[metric]; ok {
+				column = c
+			}
+			metricLabels := labels
+			if l, ok := metricsLabels[metric]; ok {
+				metricLabels = l
+			}
+			// Construct labels value
+			labelsValues := []string{}
+			for _, label := range metricLabels {
+				labelsValues = append(labelsValues, row[label])
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(row[column]), 64)
 			// If not a float, skip current metric
 			if err != nil {
-				level.Error(e.logger).Log("msg", "Unable to convert current value to float", "metric", metric, "metricHelp", metricHelp, "value", row[metric])
+				level.Error(e.logger).Log("msg", "Unable to convert current value to float", "metric", metric, "metricHelp", metricHelp, "value", row[column])
 				continue
 			}
 			level.Debug(e.logger).Log("Query result looks like: ", value)
@@ -466,7 +896,7 @@ func (e *Exporter) scrapeGenericValues(db *sql.DB, ch chan<- prometheus.Metric,
 				desc := prometheus.NewDesc(
 					prometheus.BuildFQName(namespace, context, metric),
 					metricHelp,
-					labels, nil,
+					metricLabels, nil,
 				)
 				if metricsType[strings.ToLower(metric)] == "histogram" {
 					count, err := strconv.ParseUint(strings.TrimSpace(row["count"]), 10, 64)
@@ -535,7 +965,8 @@ func (e *Exporter) scrapeGenericValues(db *sql.DB, ch chan<- prometheus.Metric,
 		return nil
 	}
 	level.Debug(e.logger).Log("Calling function GeneratePrometheusMetrics()")
-	err := e.generatePrometheusMetrics(db, genericParser, request)
+	err := e.generatePrometheusMetrics(ctx, backend, genericParser, request, queryTimeout)
+	e.rowsReturned.WithLabelValues(context).Set(float64(rowsCount))
 	level.Debug(e.logger).Log("ScrapeGenericValues() - metricsCount: ", metricsCount)
 	if err != nil {
 		return err
@@ -546,43 +977,30 @@ func (e *Exporter) scrapeGenericValues(db *sql.DB, ch chan<- prometheus.Metric,
 	return err
 }
 
-// inspired by https://kylewbanks.com/blog/query-result-to-map-in-golang
 // Parse SQL result and call parsing function to each row
-func (e *Exporter) generatePrometheusMetrics(db *sql.DB, parse func(row map[string]string) error, query string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.config.QueryTimeout)*time.Second)
+func (e *Exporter) generatePrometheusMetrics(ctx context.Context, backend Backend, parse func(row map[string]string) error, query string, queryTimeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
 	defer cancel()
-	rows, err := db.QueryContext(ctx, query)
+	columns, rows, err := backend.Query(ctx, query)
 
+	// The timeout may also expire while fetching the rows
 	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-		return errors.New("oracle query timed out")
+		return errQueryTimeout
 	}
 
 	if err != nil {
 		return err
 	}
-	cols, err := rows.Columns()
-	defer rows.Close()
-
-	for rows.Next() {
-		// Create a slice of interface{}'s to represent each column,
-		// and a second slice to contain pointers to each item in the columns slice.
-		columns := make([]interface{}, len(cols))
-		columnPointers := make([]interface{}, len(cols))
-		for i := range columns {
-			columnPointers[i] = &columns[i]
-		}
+	columns = lowerColumns(columns)
 
-		// Scan the result into the column pointers...
-		if err := rows.Scan(columnPointers...); err != nil {
-			return err
-		}
-
-		// Create our map, and retrieve the value for each column from the pointers slice,
-		// storing it in the map with the name of the column as the key.
-		m := make(map[string]string)
-		for i, colName := range cols {
-			val := columnPointers[i].(*interface{})
-			m[strings.ToLower(colName)] = fmt.Sprintf("%v", *val)
+	for _, values := range rows {
+		// Create our map, storing the value of each column with the name of
+		// the column as the key.
+		m := make(map[string]string, len(columns))
+		for i, column := range columns {
+			if i < len(values) {
+				m[column] = values[i]
+			}
 		}
 		// Call function to parse row
 		if err := parse(m); err != nil {
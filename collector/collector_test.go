@@ -2,14 +2,24 @@ package collector
 
 import (
 	"bytes"
-
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/promlog"
 	_ "github.com/sijms/go-ora/v2"
 	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
 )
 
 func TestMalformedDSNMasksUserPassword(t *testing.T) {
@@ -25,3 +35,582 @@ func TestMalformedDSNMasksUserPassword(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Contains(t, buf.String(), "malformedDSN:=***@")
 }
+
+// metricCollector collects a single metric definition from a backend
+type metricCollector struct {
+	exporter *Exporter
+	backend  Backend
+	metric   Metric
+}
+
+func (c metricCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c metricCollector) Collect(ch chan<- prometheus.Metric) {
+	if err := c.exporter.ScrapeMetric(c.backend, ch, c.metric); err != nil {
+		panic(err)
+	}
+}
+
+func TestScrapeMetricFromFakeBackend(t *testing.T) {
+	e := newExporter(log.NewNopLogger(), &Config{QueryTimeout: 5})
+	for _, test := range []struct {
+		name     string
+		metric   Metric
+		fixture  Fixture
+		expected string
+	}{
+		{
+			name: "gauge",
+			metric: Metric{
+				Context:     "sessions",
+				Labels:      []string{"status"},
+				MetricsDesc: map[string]string{"value": "Number of sessions."},
+				Request:     "SELECT status, COUNT(*) as value FROM v$session GROUP BY status",
+			},
+			fixture: Fixture{Columns: []string{"STATUS", "VALUE"}, Rows: [][]string{{"ACTIVE", "3"}, {"INACTIVE", "12"}}},
+			expected: `
+# HELP oracledb_sessions_value Number of sessions.
+# TYPE oracledb_sessions_value gauge
+oracledb_sessions_value{status="ACTIVE"} 3
+oracledb_sessions_value{status="INACTIVE"} 12
+`,
+		},
+		{
+			name: "counter",
+			metric: Metric{
+				Context:     "sysstat",
+				MetricsDesc: map[string]string{"commits": "User commits.", "rollbacks": "User rollbacks."},
+				MetricsType: map[string]string{"commits": "counter"},
+				Request:     "SELECT 42 as commits, 7 as rollbacks FROM dual",
+			},
+			fixture: Fixture{Columns: []string{"COMMITS", "ROLLBACKS"}, Rows: [][]string{{"42", "7"}}},
+			expected: `
+# HELP oracledb_sysstat_commits User commits.
+# TYPE oracledb_sysstat_commits counter
+oracledb_sysstat_commits 42
+# HELP oracledb_sysstat_rollbacks User rollbacks.
+# TYPE oracledb_sysstat_rollbacks gauge
+oracledb_sysstat_rollbacks 7
+`,
+		},
+		{
+			name: "histogram",
+			metric: Metric{
+				Context:        "queries",
+				Labels:         []string{"schema"},
+				MetricsDesc:    map[string]string{"duration": "Duration of the queries."},
+				MetricsType:    map[string]string{"duration": "histogram"},
+				MetricsBuckets: map[string]map[string]string{"duration": {"le_1": "1", "le_5": "5"}},
+				Request:        "SELECT schema, duration, count, le_1, le_5 FROM queries",
+			},
+			fixture: Fixture{Columns: []string{"SCHEMA", "DURATION", "COUNT", "LE_1", "LE_5"}, Rows: [][]string{{"APP", "9.5", "4", "2", "3"}}},
+			expected: `
+# HELP oracledb_queries_duration Duration of the queries.
+# TYPE oracledb_queries_duration histogram
+oracledb_queries_duration_bucket{schema="APP",le="1"} 2
+oracledb_queries_duration_bucket{schema="APP",le="5"} 3
+oracledb_queries_duration_bucket{schema="APP",le="+Inf"} 4
+oracledb_queries_duration_sum{schema="APP"} 9.5
+oracledb_queries_duration_count{schema="APP"} 4
+`,
+		},
+		{
+			name: "fieldtoappend",
+			metric: Metric{
+				Context:       "activity",
+				MetricsDesc:   map[string]string{"value": "Activity."},
+				FieldToAppend: "name",
+				Request:       "SELECT name, value FROM v$sysstat",
+			},
+			fixture: Fixture{Columns: []string{"NAME", "VALUE"}, Rows: [][]string{{"user commits", "42"}, {"execute count", "1000"}}},
+			expected: `
+# HELP oracledb_activity_execute_count Activity.
+# TYPE oracledb_activity_execute_count gauge
+oracledb_activity_execute_count 1000
+# HELP oracledb_activity_user_commits Activity.
+# TYPE oracledb_activity_user_commits gauge
+oracledb_activity_user_commits 42
+`,
+		},
+		{
+			name: "v2 labels and columns",
+			metric: func() Metric {
+				metric, err := QueryV2{
+					Context: "tablespace",
+					Request: "SELECT tablespace, type, bytes, max_bytes FROM dba_tablespace_usage",
+					Metric: []MetricV2{
+						{Name: "used", Column: "bytes", Unit: "bytes", Help: "Used bytes.", Labels: []string{"tablespace", "type"}},
+						{Name: "max_bytes", Help: "Maximum size.", Labels: []string{"tablespace"}},
+					},
+				}.Definition()
+				assert.NoError(t, err)
+				return metric
+			}(),
+			fixture: Fixture{Columns: []string{"TABLESPACE", "TYPE", "BYTES", "MAX_BYTES"}, Rows: [][]string{{"USERS", "PERMANENT", "1024", "4096"}}},
+			expected: `
+# HELP oracledb_tablespace_max_bytes Maximum size.
+# TYPE oracledb_tablespace_max_bytes gauge
+oracledb_tablespace_max_bytes{tablespace="USERS"} 4096
+# HELP oracledb_tablespace_used_bytes Used bytes.
+# TYPE oracledb_tablespace_used_bytes gauge
+oracledb_tablespace_used_bytes{tablespace="USERS",type="PERMANENT"} 1024
+`,
+		},
+	} {
+		test.fixture.Request = test.metric.Request
+		c := metricCollector{exporter: e, backend: NewFakeBackend(test.fixture), metric: test.metric}
+		assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(test.expected)), test.name)
+	}
+}
+
+func TestCollectReportsDownBackend(t *testing.T) {
+	e := newExporter(log.NewNopLogger(), &Config{QueryTimeout: 5})
+	backend := NewFakeBackend()
+	backend.SetPingError(errors.New("ORA-12541: TNS:no listener"))
+	e.backend = backend
+
+	expected := `
+# HELP oracledb_up Whether the Oracle database server is up.
+# TYPE oracledb_up gauge
+oracledb_up 0
+`
+	assert.NoError(t, testutil.CollectAndCompare(e, strings.NewReader(expected), "oracledb_up"))
+}
+
+func TestExportersSideBySide(t *testing.T) {
+	dir := t.TempDir()
+	defaultMetrics := filepath.Join(dir, "default-metrics.toml")
+	assert.NoError(t, os.WriteFile(defaultMetrics, []byte(`
+[[metric]]
+context = "process"
+metricsdesc = { count = "Number of processes." }
+request = "SELECT COUNT(*) as count FROM v$process"
+`), 0o644))
+	fixtures := Fixtures{Fixtures: []Fixture{{
+		Request: "SELECT COUNT(*) as count FROM v$process",
+		Columns: []string{"COUNT"},
+		Rows:    [][]string{{"85"}},
+	}}}
+
+	// Each exporter has its own custom metric
+	const count = 4
+	customMetrics := func(i int) string {
+		return filepath.Join(dir, fmt.Sprintf("custom-%d.toml", i))
+	}
+	writeCustomMetrics := func(i int, help string) {
+		content := fmt.Sprintf("[[metric]]\ncontext = \"custom_%d\"\nmetricsdesc = { value = %q }\nrequest = \"SELECT %d as value FROM dual\"\n", i, help, i)
+		assert.NoError(t, os.WriteFile(customMetrics(i), []byte(content), 0o644))
+	}
+	helps := make([]string, count)
+	names := []string{"oracledb_process_count"}
+	for i := 0; i < count; i++ {
+		helps[i] = fmt.Sprintf("Custom metric %d.", i)
+		writeCustomMetrics(i, helps[i])
+		fixtures.Fixtures = append(fixtures.Fixtures, Fixture{
+			Request: fmt.Sprintf("SELECT %d as value FROM dual", i),
+			Columns: []string{"VALUE"},
+			Rows:    [][]string{{strconv.Itoa(i)}},
+		})
+		names = append(names, fmt.Sprintf("oracledb_custom_%d_value", i))
+	}
+	fixturesFile := filepath.Join(dir, "fixtures.yaml")
+	content, err := yaml.Marshal(fixtures)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(fixturesFile, content, 0o644))
+
+	// The exporters are created concurrently from the same configuration
+	config := &Config{DefaultMetricsFile: defaultMetrics, QueryTimeout: 5, ReplayFile: fixturesFile}
+	exporters := make([]*Exporter, count)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cfg := *config
+			cfg.CustomMetrics = customMetrics(i)
+			e, err := NewExporter(log.NewNopLogger(), &cfg)
+			assert.NoError(t, err)
+			exporters[i] = e
+		}(i)
+	}
+	wg.Wait()
+
+	collectAll := func() {
+		for i, e := range exporters {
+			expected := fmt.Sprintf(`
+# HELP oracledb_custom_%d_value %s
+# TYPE oracledb_custom_%d_value gauge
+oracledb_custom_%d_value %d
+# HELP oracledb_process_count Number of processes.
+# TYPE oracledb_process_count gauge
+oracledb_process_count 85
+`, i, helps[i], i, i, i)
+			wg.Add(1)
+			go func(i int, e *Exporter) {
+				defer wg.Done()
+				err := testutil.CollectAndCompare(e, strings.NewReader(expected), names...)
+				assert.NoError(t, err, "exporter %d", i)
+			}(i, e)
+		}
+		wg.Wait()
+	}
+	collectAll()
+
+	// Reloading an exporter doesn't affect the others
+	helps[0] = "Reloaded custom metric 0."
+	writeCustomMetrics(0, helps[0])
+	assert.NoError(t, exporters[0].Reload())
+	collectAll()
+}
+
+// fakeExporter returns an exporter scraping the metrics from a FakeBackend
+// answering the fixtures
+func fakeExporter(config Config, metrics []Metric, fixtures ...Fixture) (*Exporter, *FakeBackend) {
+	if config.QueryTimeout == 0 {
+		config.QueryTimeout = 5
+	}
+	e := buildExporter(log.NewNopLogger(), &config)
+	e.fileMetrics = Metrics{Metric: metrics}
+	e.useMetrics(e.fileMetrics)
+	backend := NewFakeBackend(fixtures...)
+	e.backend = backend
+	return e, backend
+}
+
+// slowBackend delays the queries of its Backend until their context is done,
+// and tracks the queries run
+type slowBackend struct {
+	Backend
+	delays map[string]time.Duration
+
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	queries    []string
+}
+
+func (b *slowBackend) Query(ctx context.Context, request string) ([]string, [][]string, error) {
+	b.mu.Lock()
+	b.running++
+	if b.running > b.maxRunning {
+		b.maxRunning = b.running
+	}
+	b.queries = append(b.queries, request)
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.running--
+		b.mu.Unlock()
+	}()
+
+	select {
+	case <-time.After(b.delays[request]):
+		return b.Backend.Query(ctx, request)
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// queried returns the requests queried so far
+func (b *slowBackend) queried() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string{}, b.queries...)
+}
+
+func TestQueryTimeoutIsReported(t *testing.T) {
+	metrics := []Metric{{
+		Context:      "slow",
+		MetricsDesc:  map[string]string{"value": "Slow value."},
+		Request:      "SELECT 1 as value FROM slow",
+		QueryTimeout: "10ms",
+	}, {
+		Context:     "failing",
+		MetricsDesc: map[string]string{"value": "Failing value."},
+		Request:     "SELECT 2 as value FROM failing",
+	}}
+	e, backend := fakeExporter(Config{BreakerThreshold: 0}, metrics,
+		Fixture{Request: metrics[0].Request, Columns: []string{"VALUE"}, Rows: [][]string{{"1"}}})
+	backend.SetError(metrics[1].Request, errors.New("ORA-00942: table or view does not exist"))
+	e.backend = &slowBackend{Backend: backend, delays: map[string]time.Duration{metrics[0].Request: time.Minute}}
+
+	// The exporter is scraped twice, to be described and then collected
+	expected := `
+# HELP oracledb_exporter_scrape_errors_total Total number of times an error occurred scraping a Oracle database.
+# TYPE oracledb_exporter_scrape_errors_total counter
+oracledb_exporter_scrape_errors_total{collector="failing",reason="error"} 2
+oracledb_exporter_scrape_errors_total{collector="slow",reason="timeout"} 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(e, strings.NewReader(expected), "oracledb_exporter_scrape_errors_total"))
+}
+
+func TestScrapeConcurrencyIsLimited(t *testing.T) {
+	var metrics []Metric
+	delays := make(map[string]time.Duration)
+	backend := NewFakeBackend()
+	for i := 0; i < 6; i++ {
+		request := fmt.Sprintf("SELECT %d as value FROM dual", i)
+		metrics = append(metrics, Metric{
+			Context:     fmt.Sprintf("metric_%d", i),
+			MetricsDesc: map[string]string{"value": "Value."},
+			Request:     request,
+		})
+		backend.SetResult(Fixture{Request: request, Columns: []string{"VALUE"}, Rows: [][]string{{strconv.Itoa(i)}}})
+		delays[request] = 20 * time.Millisecond
+	}
+	e, _ := fakeExporter(Config{ScrapeConcurrency: 2}, metrics)
+	slow := &slowBackend{Backend: backend, delays: delays}
+	e.backend = slow
+
+	assert.Equal(t, 6, testutil.CollectAndCount(scrapeCollector{exporter: e}))
+	assert.Equal(t, 2, slow.maxRunning)
+}
+
+func TestScrapePriority(t *testing.T) {
+	var metrics []Metric
+	backend := NewFakeBackend()
+	for i, priority := range []int{0, 5, 1, 5} {
+		request := fmt.Sprintf("SELECT %d as value FROM dual", i)
+		metrics = append(metrics, Metric{
+			Context:     fmt.Sprintf("metric_%d", i),
+			MetricsDesc: map[string]string{"value": "Value."},
+			Request:     request,
+			Priority:    priority,
+		})
+		backend.SetResult(Fixture{Request: request, Columns: []string{"VALUE"}, Rows: [][]string{{strconv.Itoa(i)}}})
+	}
+	e, _ := fakeExporter(Config{ScrapeConcurrency: 1}, metrics)
+	slow := &slowBackend{Backend: backend}
+	e.backend = slow
+
+	testutil.CollectAndCount(scrapeCollector{exporter: e})
+	// Metrics with the same priority keep their order
+	assert.Equal(t, []string{
+		"SELECT 1 as value FROM dual",
+		"SELECT 3 as value FROM dual",
+		"SELECT 2 as value FROM dual",
+		"SELECT 0 as value FROM dual",
+	}, slow.queried())
+}
+
+// contextCollector collects the exporter with a context, without describing
+// it first
+type contextCollector struct {
+	exporter *Exporter
+	ctx      context.Context
+}
+
+func (c contextCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c contextCollector) Collect(ch chan<- prometheus.Metric) {
+	c.exporter.CollectContext(c.ctx, ch)
+}
+
+// valueMetric returns a metric with a single value, along with the result of
+// its request
+func valueMetric(context string, value string) (Metric, Fixture) {
+	request := fmt.Sprintf("SELECT value FROM %s", context)
+	metric := Metric{
+		Context:     context,
+		MetricsDesc: map[string]string{"value": "Value."},
+		Request:     request,
+	}
+	return metric, Fixture{Request: request, Columns: []string{"VALUE"}, Rows: [][]string{{value}}}
+}
+
+// expectedValue returns the exposition of a metric returned by valueMetric
+func expectedValue(context string, value string) *strings.Reader {
+	return strings.NewReader(fmt.Sprintf(`
+# HELP oracledb_%[1]s_value Value.
+# TYPE oracledb_%[1]s_value gauge
+oracledb_%[1]s_value %[2]s
+`, context, value))
+}
+
+func TestConcurrentCollectsShareScrape(t *testing.T) {
+	metric, fixture := valueMetric("shared", "1")
+	e, backend := fakeExporter(Config{}, []Metric{metric}, fixture)
+	slow := &slowBackend{Backend: backend, delays: map[string]time.Duration{metric.Request: 100 * time.Millisecond}}
+	e.backend = slow
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, testutil.CollectAndCompare(contextCollector{e, context.Background()},
+				expectedValue("shared", "1"), "oracledb_shared_value"))
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, slow.queried(), 1)
+}
+
+func TestScrapeMinIntervalReusesResults(t *testing.T) {
+	metric, fixture := valueMetric("reused", "1")
+	e, backend := fakeExporter(Config{ScrapeMinInterval: time.Hour}, []Metric{metric}, fixture)
+	slow := &slowBackend{Backend: backend}
+	e.backend = slow
+	collector := contextCollector{e, context.Background()}
+
+	assert.NoError(t, testutil.CollectAndCompare(collector, expectedValue("reused", "1"), "oracledb_reused_value"))
+	_, fixture = valueMetric("reused", "2")
+	backend.SetResult(fixture)
+	assert.NoError(t, testutil.CollectAndCompare(collector, expectedValue("reused", "1"), "oracledb_reused_value"))
+	assert.Len(t, slow.queried(), 1)
+
+	// The database is scraped again once the interval has elapsed
+	e.lastScrape = time.Now().Add(-time.Hour)
+	assert.NoError(t, testutil.CollectAndCompare(collector, expectedValue("reused", "2"), "oracledb_reused_value"))
+	assert.Len(t, slow.queried(), 2)
+}
+
+func TestCancelledScrapeIsNotReused(t *testing.T) {
+	fastMetric, fastFixture := valueMetric("fast", "1")
+	slowMetric, slowFixture := valueMetric("slow", "2")
+	e, backend := fakeExporter(Config{ScrapeMinInterval: time.Hour},
+		[]Metric{fastMetric, slowMetric}, fastFixture, slowFixture)
+	slow := &slowBackend{Backend: backend, delays: map[string]time.Duration{slowMetric.Request: time.Minute}}
+	e.backend = slow
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.NoError(t, testutil.CollectAndCompare(contextCollector{e, ctx},
+		expectedValue("fast", "1"), "oracledb_fast_value", "oracledb_slow_value"))
+
+	// The partial results of the cancelled scrape are not served again
+	slow.delays = nil
+	expected := `
+# HELP oracledb_fast_value Value.
+# TYPE oracledb_fast_value gauge
+oracledb_fast_value 1
+# HELP oracledb_slow_value Value.
+# TYPE oracledb_slow_value gauge
+oracledb_slow_value 2
+`
+	assert.NoError(t, testutil.CollectAndCompare(contextCollector{e, context.Background()},
+		strings.NewReader(expected), "oracledb_fast_value", "oracledb_slow_value"))
+	assert.Len(t, slow.queried(), 4)
+}
+
+func TestSharedScrapeOutlivesFirstCaller(t *testing.T) {
+	metric, fixture := valueMetric("slow", "1")
+	e, backend := fakeExporter(Config{}, []Metric{metric}, fixture)
+	slow := &slowBackend{Backend: backend, delays: map[string]time.Duration{metric.Request: 200 * time.Millisecond}}
+	e.backend = slow
+
+	// The first caller gives up before the end of the scrape it started
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		testutil.CollectAndCount(contextCollector{e, ctx})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	assert.NoError(t, testutil.CollectAndCompare(contextCollector{e, context.Background()},
+		expectedValue("slow", "1"), "oracledb_slow_value"))
+	<-done
+	assert.Len(t, slow.queried(), 1)
+}
+
+func TestScrapeResultsPerContext(t *testing.T) {
+	metrics := []Metric{{
+		Context:     "ok",
+		Labels:      []string{"name"},
+		MetricsDesc: map[string]string{"value": "Value."},
+		Request:     "SELECT name, value FROM ok",
+	}, {
+		Context:     "failing",
+		MetricsDesc: map[string]string{"value": "Value."},
+		Request:     "SELECT value FROM failing",
+	}}
+	e, backend := fakeExporter(Config{}, metrics, Fixture{
+		Request: metrics[0].Request,
+		Columns: []string{"NAME", "VALUE"},
+		Rows:    [][]string{{"a", "1"}, {"b", "2"}, {"c", "3"}},
+	})
+	backend.SetError(metrics[1].Request, errors.New("ORA-00942: table or view does not exist"))
+	e.backend = &slowBackend{Backend: backend, delays: map[string]time.Duration{metrics[0].Request: 20 * time.Millisecond}}
+	testutil.CollectAndCount(scrapeCollector{exporter: e})
+
+	assert.NoError(t, testutil.CollectAndCompare(e.scrapeSuccess, strings.NewReader(`
+# HELP oracledb_exporter_scrape_success Whether the last scrape of the metric context succeeded (1 for success, 0 for error).
+# TYPE oracledb_exporter_scrape_success gauge
+oracledb_exporter_scrape_success{context="failing"} 0
+oracledb_exporter_scrape_success{context="ok"} 1
+`)))
+	assert.NoError(t, testutil.CollectAndCompare(e.rowsReturned, strings.NewReader(`
+# HELP oracledb_exporter_rows_returned Number of rows returned by the request of the metric context during its last scrape.
+# TYPE oracledb_exporter_rows_returned gauge
+oracledb_exporter_rows_returned{context="failing"} 0
+oracledb_exporter_rows_returned{context="ok"} 3
+`)))
+	assert.Equal(t, 2, testutil.CollectAndCount(e.scrapeDuration))
+	assert.GreaterOrEqual(t, testutil.ToFloat64(e.scrapeDuration.WithLabelValues("ok")), 0.02)
+	assert.Equal(t, 1, testutil.CollectAndCount(e.lastSuccess))
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(e.lastSuccess.WithLabelValues("ok")), 5)
+}
+
+func TestFailedReloadKeepsMetrics(t *testing.T) {
+	metricsFile := filepath.Join(t.TempDir(), "default-metrics.toml")
+	assert.NoError(t, os.WriteFile(metricsFile, []byte(`
+[[metric]]
+context = "kept"
+metricsdesc = { value = "Value." }
+request = "SELECT value FROM kept"
+`), 0o644))
+	_, fixture := valueMetric("kept", "1")
+	e, _ := fakeExporter(Config{DefaultMetricsFile: metricsFile}, nil, fixture)
+	assert.NoError(t, e.Reload())
+	assert.Equal(t, 1.0, testutil.ToFloat64(e.configReloadSuccess))
+
+	assert.NoError(t, os.WriteFile(metricsFile, []byte("[[metric]\ncontext = \"broken\"\n"), 0o644))
+	assert.Error(t, e.Reload())
+	assert.Equal(t, 0.0, testutil.ToFloat64(e.configReloadSuccess))
+	assert.Equal(t, []string{"kept"}, metricContexts(e))
+	assert.NoError(t, testutil.CollectAndCompare(contextCollector{e, context.Background()},
+		expectedValue("kept", "1"), "oracledb_kept_value"))
+}
+
+func TestUnreadableDefaultMetricsFileFallsBack(t *testing.T) {
+	customMetrics := filepath.Join(t.TempDir(), "custom-metrics.toml")
+	assert.NoError(t, os.WriteFile(customMetrics, []byte(`
+[[metric]]
+context = "custom"
+metricsdesc = { value = "Value." }
+request = "SELECT value FROM custom"
+`), 0o644))
+
+	// The embedded default metrics are used along with the custom metrics
+	e := newExporter(log.NewNopLogger(), &Config{DefaultMetricsFile: "missing.toml", CustomMetrics: customMetrics})
+	var expected []string
+	for _, metric := range e.embeddedMetrics().Metric {
+		expected = append(expected, metric.Context)
+	}
+	assert.Equal(t, append(expected, "custom"), metricContexts(e))
+	assert.Equal(t, 1.0, testutil.ToFloat64(e.configReloadSuccess))
+	assert.NoError(t, e.Reload())
+}
+
+func TestReloadRejectsUnknownMetricType(t *testing.T) {
+	metricsFile := filepath.Join(t.TempDir(), "default-metrics.toml")
+	writeMetrics := func(metricType string) {
+		content := fmt.Sprintf("[[metric]]\ncontext = \"typed\"\nmetricsdesc = { value = \"Value.\" }\nmetricstype = { value = %q }\nrequest = \"SELECT value FROM typed\"\n", metricType)
+		assert.NoError(t, os.WriteFile(metricsFile, []byte(content), 0o644))
+	}
+	writeMetrics("counter")
+	_, fixture := valueMetric("typed", "1")
+	e, _ := fakeExporter(Config{DefaultMetricsFile: metricsFile}, nil, fixture)
+	assert.NoError(t, e.Reload())
+
+	writeMetrics("gage")
+	assert.ErrorContains(t, e.Reload(), "unknown metricstype gage")
+	assert.Equal(t, "counter", e.Metrics().Metric[0].MetricsType["value"])
+	assert.NoError(t, testutil.CollectAndCompare(contextCollector{e, context.Background()}, strings.NewReader(`
+# HELP oracledb_typed_value Value.
+# TYPE oracledb_typed_value counter
+oracledb_typed_value 1
+`), "oracledb_typed_value"))
+}
@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+// startColumnCheck checks the columns of the metrics in the background, once
+// connected, once the metrics changed, or on the next scrape if the database
+// couldn't be reached. The requests are described without holding e.mu, so
+// that scrapes are not delayed, and the metrics missing columns are disabled
+// only if the metrics didn't change meanwhile. It must be called with e.mu
+// held, or before the exporter is used.
+func (e *Exporter) startColumnCheck() {
+	if e.closed || e.columnsChecked || e.columnCheckRunning || e.backend == nil {
+		return
+	}
+	e.columnCheckRunning = true
+	version := e.metricsVersion
+	backend := e.backend
+	metrics := make([]Metric, len(e.metricsToScrape.Metric))
+	copy(metrics, e.metricsToScrape.Metric)
+	go func() {
+		missing, err := e.checkColumns(context.Background(), backend, metrics)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.metricsVersion != version {
+			return
+		}
+		e.columnCheckRunning = false
+		if err != nil {
+			level.Debug(e.logger).Log("msg", "Unable to check the columns of the metrics, postponing the check", "error", err)
+			return
+		}
+		e.missingColumns = missing
+		for _, metric := range metrics {
+			if _, ok := missing[metricKey(metric)]; ok {
+				e.metricDisabled.WithLabelValues(metric.Context).Set(1)
+			}
+		}
+		e.columnsChecked = true
+	}()
+}
+
+// checkColumns describes the request of each metric, ScrapeConcurrency at a
+// time, and returns the columns missing from their result by metric key.
+// These metrics would otherwise produce empty labels or fail on every
+// scrape. Requests which can't be described, for instance because of a
+// missing grant, are left to fail while being scraped. The check fails if
+// the database cannot be reached or if ctx is done before its end.
+func (e *Exporter) checkColumns(ctx context.Context, backend Backend, metrics []Metric) (map[string][]string, error) {
+	pingCtx, cancel := context.WithTimeout(ctx, time.Duration(e.config.QueryTimeout)*time.Second)
+	err := backend.Ping(pingCtx)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	missingByKey := make(map[string][]string)
+	check := func(metric Metric) {
+		columns, err := describeRequest(ctx, backend, metric.Request, e.metricQueryTimeout(metric))
+		if err != nil {
+			level.Debug(e.logger).Log("msg", "Unable to describe request, skipping column check", "context", metric.Context, "error", err)
+			return
+		}
+		missing := missingColumns(metric, columns)
+		if len(missing) == 0 {
+			return
+		}
+		level.Error(e.logger).Log("msg", "Columns missing from the result of the request, disabling metric", "context", metric.Context, "missing", strings.Join(missing, ","), "returned", strings.Join(columns, ","))
+		mu.Lock()
+		missingByKey[metricKey(metric)] = missing
+		mu.Unlock()
+	}
+
+	workers := e.config.ScrapeConcurrency
+	if workers <= 0 || workers > len(metrics) {
+		workers = len(metrics)
+	}
+	queue := make(chan Metric)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for metric := range queue {
+				check(metric)
+			}
+		}()
+	}
+	for _, metric := range metrics {
+		if metric.Request != "" {
+			queue <- metric
+		}
+	}
+	close(queue)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return missingByKey, nil
+}
+
+// describeMetric returns the lower cased columns returned by the request of
+// the metric, without fetching any row
+func (e *Exporter) describeMetric(ctx context.Context, metric Metric) ([]string, error) {
+	return describeRequest(ctx, e.backend, metric.Request, e.metricQueryTimeout(metric))
+}
+
+// describeRequest returns the lower cased columns returned by the request
+func describeRequest(ctx context.Context, backend Backend, request string, timeout time.Duration) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	columns, err := backend.Describe(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return lowerColumns(columns), nil
+}
+
+// missingColumns returns the columns used by the metric which are not in
+// columns
+func missingColumns(metric Metric, columns []string) []string {
+	returned := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		returned[column] = true
+	}
+	missing := make(map[string]bool)
+	use := func(column string) {
+		if !returned[column] {
+			missing[column] = true
+		}
+	}
+
+	for name := range metric.MetricsDesc {
+		use(metric.column(name))
+		for _, label := range metric.labels(name) {
+			use(label)
+		}
+		if strings.EqualFold(metric.MetricsType[name], "histogram") {
+			use("count")
+			for field := range metric.MetricsBuckets[name] {
+				use(field)
+			}
+		}
+	}
+	if metric.FieldToAppend != "" {
+		use(metric.FieldToAppend)
+	}
+
+	names := make([]string, 0, len(missing))
+	for column := range missing {
+		names = append(names, column)
+	}
+	sort.Strings(names)
+	return names
+}
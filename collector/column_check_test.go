@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMissingColumns(t *testing.T) {
+	metric := Metric{
+		Context:        "test",
+		Labels:         []string{"label_1", "label_2"},
+		MetricsDesc:    map[string]string{"value": "Value.", "duration": "Duration."},
+		MetricsType:    map[string]string{"duration": "histogram"},
+		MetricsBuckets: map[string]map[string]string{"duration": {"le_1": "1", "le_10": "10"}},
+	}
+	assert.Empty(t, missingColumns(metric, []string{"label_1", "label_2", "value", "duration", "count", "le_1", "le_10"}))
+	assert.Equal(t, []string{"count", "label_2", "le_10", "value"}, missingColumns(metric, []string{"label_1", "duration", "le_1"}))
+
+	metric = Metric{
+		Context:       "test",
+		MetricsDesc:   map[string]string{"value": "Value."},
+		FieldToAppend: "name",
+	}
+	assert.Equal(t, []string{"name"}, missingColumns(metric, []string{"value"}))
+}
+
+func TestMissingColumnsOfV2Metrics(t *testing.T) {
+	metric, err := QueryV2{
+		Context: "test",
+		Request: "SELECT 1 as bytes, 'a' as label_1 FROM dual",
+		Metric: []MetricV2{
+			{Name: "used", Column: "bytes", Help: "Used.", Labels: []string{"label_1"}},
+			{Name: "max", Column: "max_bytes", Help: "Max.", Labels: []string{"label_2"}},
+		},
+	}.Definition()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"label_2", "max_bytes"}, missingColumns(metric, []string{"bytes", "label_1"}))
+}
+
+func TestColumnsCheckedOnConnectAndOnChange(t *testing.T) {
+	metric, fixture := valueMetric("missing", "1")
+	fixture.Columns = []string{"OTHER"}
+	e, backend := fakeExporter(Config{}, []Metric{metric}, fixture)
+	disabled := func() bool {
+		return testutil.CollectAndCount(e.metricDisabled) == 1
+	}
+	assert.NoError(t, e.open(backend))
+	assert.Eventually(t, disabled, 5*time.Second, 10*time.Millisecond)
+
+	// The metrics set afterwards are checked as well
+	assert.NoError(t, e.SetMetrics(Metrics{Metric: []Metric{metric}}))
+	assert.Eventually(t, disabled, 5*time.Second, 10*time.Millisecond)
+}
+
+// blockingDescribeBackend holds the descriptions of the requests until
+// released
+type blockingDescribeBackend struct {
+	Backend
+	release chan struct{}
+}
+
+func (b blockingDescribeBackend) Describe(ctx context.Context, request string) ([]string, error) {
+	select {
+	case <-b.release:
+		return b.Backend.Describe(ctx, request)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestColumnCheckDoesNotBlockScrapes(t *testing.T) {
+	missingMetric, missingFixture := valueMetric("missing", "1")
+	missingFixture.Columns = []string{"OTHER"}
+	okMetric, okFixture := valueMetric("ok", "2")
+	e, backend := fakeExporter(Config{}, []Metric{missingMetric, okMetric}, missingFixture, okFixture)
+	release := make(chan struct{})
+	assert.NoError(t, e.open(blockingDescribeBackend{Backend: backend, release: release}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, testutil.CollectAndCompare(contextCollector{e, context.Background()},
+			expectedValue("ok", "2"), "oracledb_ok_value"))
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scrape blocked by the column check")
+	}
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		return testutil.CollectAndCount(e.metricDisabled) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	assert.Equal(t, map[string][]string{metricKey(missingMetric): {"value"}}, e.missingColumns)
+}
+
+func TestCancelledColumnCheckFails(t *testing.T) {
+	metric, fixture := valueMetric("missing", "1")
+	fixture.Columns = []string{"OTHER"}
+	e, backend := fakeExporter(Config{}, []Metric{metric}, fixture)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := e.checkColumns(ctx, backend, []Metric{metric})
+	assert.ErrorIs(t, err, context.Canceled)
+	missing, err := e.checkColumns(context.Background(), backend, []Metric{metric})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{metricKey(metric): {"value"}}, missing)
+}
@@ -2,7 +2,6 @@ package collector
 
 import (
 	"errors"
-	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/go-kit/log/level"
@@ -57,14 +56,14 @@ WHERE
 [[metric]]
 context = "tablespace"
 labels = [ "tablespace", "type" ]
-metricsdesc = { bytes = "Generic counter metric of tablespaces bytes in Oracle.", max_bytes = "Generic counter metric of tablespaces max bytes in Oracle.", free = "Generic counter metric of tablespaces free bytes in Oracle.", used_percent = "Gauge metric showing as a percentage of how much of the tablespace has been used." }
+metricsdesc = { bytes = "Generic counter metric of tablespaces bytes in Oracle.", max_bytes = "Generic counter metric of tablespaces max bytes in Oracle.", free_bytes = "Generic counter metric of tablespaces free bytes in Oracle.", used_percent = "Gauge metric showing as a percentage of how much of the tablespace has been used." }
 request = '''
 SELECT
     dt.tablespace_name as tablespace,
     dt.contents as type,
     dt.block_size * dtum.used_space as bytes,
     dt.block_size * dtum.tablespace_size as max_bytes,
-    dt.block_size * (dtum.tablespace_size - dtum.used_space) as free,
+    dt.block_size * (dtum.tablespace_size - dtum.used_space) as free_bytes,
     dtum.used_percent
 FROM  dba_tablespace_usage_metrics dtum, dba_tablespaces dt
 WHERE dtum.tablespace_name = dt.tablespace_name
@@ -77,10 +76,9 @@ func (e *Exporter) DefaultMetrics() Metrics {
 	var metricsToScrape Metrics
 	var err error
 	if e.config.DefaultMetricsFile != "" {
-		if strings.HasSuffix(e.config.DefaultMetricsFile, "toml") {
-			err = loadTomlMetricsConfig(e.config.DefaultMetricsFile, &metricsToScrape)
-		} else {
-			err = loadYamlMetricsConfig(e.config.DefaultMetricsFile, &metricsToScrape)
+		err = loadMetricsConfig(e.config.DefaultMetricsFile, &metricsToScrape)
+		if err == nil {
+			err = validateMetricTypes(metricsToScrape)
 		}
 		if err == nil {
 			return metricsToScrape
@@ -89,6 +87,12 @@ func (e *Exporter) DefaultMetrics() Metrics {
 		level.Warn(e.logger).Log("msg", "proceeding to run with default metrics")
 	}
 
+	return e.embeddedMetrics()
+}
+
+// embeddedMetrics returns the default metrics embedded in the exporter
+func (e *Exporter) embeddedMetrics() Metrics {
+	var metricsToScrape Metrics
 	if _, err := toml.Decode(defaultMetricsConst, &metricsToScrape); err != nil {
 		level.Error(e.logger).Log("msg", err.Error())
 		panic(errors.New("Error while loading " + defaultMetricsConst))
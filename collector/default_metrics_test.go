@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// scrapeCollector scrapes the metrics of the exporter, without its own metrics
+type scrapeCollector struct {
+	exporter *Exporter
+}
+
+func (c scrapeCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.exporter.mu.Lock()
+	defer c.exporter.mu.Unlock()
+	c.exporter.scrape(context.Background(), ch)
+}
+
+// exposition returns the metrics of the exporter in the text format, the
+// results of the requests being canned by context
+func exposition(t *testing.T, e *Exporter, results map[string]Fixture) string {
+	backend := NewFakeBackend()
+	for _, metric := range e.metricsToScrape.Metric {
+		fixture, ok := results[metric.Context]
+		assert.True(t, ok, "no results for context %s", metric.Context)
+		fixture.Request = metric.Request
+		backend.SetResult(fixture)
+	}
+	e.backend = backend
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(scrapeCollector{exporter: e})
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	var buffer bytes.Buffer
+	for _, family := range families {
+		_, err := expfmt.MetricFamilyToText(&buffer, family)
+		assert.NoError(t, err)
+	}
+	return buffer.String()
+}
+
+func TestDefaultMetricsGolden(t *testing.T) {
+	content, err := os.ReadFile("testdata/default-metrics-results.yaml")
+	assert.NoError(t, err)
+	var results map[string]Fixture
+	assert.NoError(t, yaml.Unmarshal(content, &results))
+
+	for _, test := range []struct {
+		// metricsFile is empty for the embedded default metrics
+		metricsFile string
+		golden      string
+	}{
+		{"../default-metrics.toml", "default-metrics.prom"},
+		{"../default-metrics.yaml", "default-metrics.prom"},
+		{"", "default-metrics.prom"},
+		{"../default-metrics.legacy-tablespace.toml", "default-metrics.legacy-tablespace.prom"},
+		{"../default-asm-metrics.toml", "default-asm-metrics.prom"},
+	} {
+		name := test.metricsFile
+		if name == "" {
+			name = "embedded default metrics"
+		}
+		e := newExporter(log.NewNopLogger(), &Config{DefaultMetricsFile: test.metricsFile, QueryTimeout: 5, StrictMetrics: true})
+		_, err := e.loadMetrics()
+		assert.NoError(t, err, name)
+
+		actual := exposition(t, e, results)
+		golden := filepath.Join("testdata", test.golden)
+		if *update && !strings.HasSuffix(test.metricsFile, ".yaml") && test.metricsFile != "" {
+			assert.NoError(t, os.WriteFile(golden, []byte(actual), 0o644))
+		}
+		expected, err := os.ReadFile(golden)
+		assert.NoError(t, err, name)
+		assert.Equal(t, string(expected), actual, name)
+	}
+}
@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// dictionaryObjectRE matches the dictionary views referenced by requests
+	dictionaryObjectRE = regexp.MustCompile(`(?i)\b(sys\.)?(g?v\$|dba_|cdb_)[a-z0-9_$#]+`)
+	// privilegeErrorRE matches the errors returned when selecting from an
+	// object without the needed grant
+	privilegeErrorRE = regexp.MustCompile(`ORA-(00942|01031|04043)\b`)
+	// stringLiteralRE matches the string literals of requests
+	stringLiteralRE = regexp.MustCompile(`'[^']*'`)
+)
+
+// Diagnosis is the result of the check of the requests of an exporter
+type Diagnosis struct {
+	// User is the database user of the exporter
+	User    string
+	Metrics []MetricDiagnosis
+}
+
+// MetricDiagnosis is the result of the check of the request of a metric
+type MetricDiagnosis struct {
+	Context string
+	Err     error
+	// MissingGrants are the objects referenced by the request which the user
+	// can't select from
+	MissingGrants []string
+}
+
+// Diagnose checks that the request of each metric can be run by the user of
+// the exporter and returns the columns used by the metric, without fetching
+// any row. When a request fails, each dictionary view it references is
+// checked to find the missing grants.
+func (e *Exporter) Diagnose(ctx context.Context) (Diagnosis, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var diagnosis Diagnosis
+	_, rows, err := e.backend.Query(ctx, "SELECT USER FROM dual")
+	if err != nil {
+		return diagnosis, err
+	}
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return diagnosis, errors.New("unable to get the user of the exporter")
+	}
+	diagnosis.User = rows[0][0]
+
+	accessible := make(map[string]bool)
+	for _, metric := range e.metricsToScrape.Metric {
+		result := MetricDiagnosis{Context: metric.Context}
+		columns, err := e.describeMetric(ctx, metric)
+		if err != nil {
+			result.Err = err
+			for _, object := range referencedObjects(metric.Request) {
+				if _, ok := accessible[object]; !ok {
+					accessible[object] = e.canSelect(ctx, object)
+				}
+				if !accessible[object] {
+					result.MissingGrants = append(result.MissingGrants, object)
+				}
+			}
+		} else if missing := missingColumns(metric, columns); len(missing) > 0 {
+			result.Err = fmt.Errorf("columns missing from the result of the request: %s", strings.Join(missing, ", "))
+		}
+		diagnosis.Metrics = append(diagnosis.Metrics, result)
+	}
+	return diagnosis, nil
+}
+
+// canSelect tells whether the user of the exporter may select from the object
+func (e *Exporter) canSelect(ctx context.Context, object string) bool {
+	_, err := describeRequest(ctx, e.backend, "SELECT * FROM "+object, e.metricQueryTimeout(Metric{}))
+	return err == nil || !privilegeErrorRE.MatchString(err.Error())
+}
+
+// Failed tells whether the request of any metric failed
+func (d Diagnosis) Failed() bool {
+	for _, metric := range d.Metrics {
+		if metric.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Grants returns the statements granting the user the missing privileges.
+// Dynamic performance views are granted through their underlying v_$ view,
+// as their public synonyms can't be granted.
+func (d Diagnosis) Grants() []string {
+	objects := make(map[string]bool)
+	for _, metric := range d.Metrics {
+		for _, object := range metric.MissingGrants {
+			objects[grantedObject(object)] = true
+		}
+	}
+	grants := make([]string, 0, len(objects))
+	for object := range objects {
+		grants = append(grants, fmt.Sprintf("GRANT SELECT ON %s TO %s;", object, d.User))
+	}
+	sort.Strings(grants)
+	return grants
+}
+
+// referencedObjects returns the lower cased dictionary views referenced by
+// the request, outside of its string literals
+func referencedObjects(request string) []string {
+	seen := make(map[string]bool)
+	var objects []string
+	request = stringLiteralRE.ReplaceAllString(request, "''")
+	for _, object := range dictionaryObjectRE.FindAllString(request, -1) {
+		object = strings.TrimPrefix(strings.ToLower(object), "sys.")
+		if !seen[object] {
+			seen[object] = true
+			objects = append(objects, object)
+		}
+	}
+	sort.Strings(objects)
+	return objects
+}
+
+// grantedObject returns the object to grant to give access to the view
+func grantedObject(object string) string {
+	if prefix, name, ok := strings.Cut(object, "$"); ok && (prefix == "v" || prefix == "gv") {
+		return "sys." + prefix + "_$" + name
+	}
+	return "sys." + object
+}
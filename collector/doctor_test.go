@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferencedObjects(t *testing.T) {
+	request := `SELECT s.status, COUNT(*) as value FROM v$session s, SYS.GV$PROCESS p, dba_tablespaces t
+		WHERE s.paddr = p.addr AND EXISTS (SELECT 1 FROM v$session WHERE username = 'dba_user')`
+	assert.Equal(t, []string{"dba_tablespaces", "gv$process", "v$session"}, referencedObjects(request))
+}
+
+func TestDiagnosisGrants(t *testing.T) {
+	diagnosis := Diagnosis{
+		User: "EXPORTER",
+		Metrics: []MetricDiagnosis{
+			{Context: "sessions"},
+			{Context: "processes", Err: errors.New("ORA-00942: table or view does not exist"), MissingGrants: []string{"gv$process", "v$session"}},
+			{Context: "tablespace", Err: errors.New("ORA-00942: table or view does not exist"), MissingGrants: []string{"dba_tablespaces", "v$session"}},
+		},
+	}
+	assert.True(t, diagnosis.Failed())
+	assert.Equal(t, []string{
+		"GRANT SELECT ON sys.dba_tablespaces TO EXPORTER;",
+		"GRANT SELECT ON sys.gv_$process TO EXPORTER;",
+		"GRANT SELECT ON sys.v_$session TO EXPORTER;",
+	}, diagnosis.Grants())
+}
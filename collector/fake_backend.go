@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeBackend is an in-memory Backend returning predefined results, to test
+// metric definitions without database. It is safe for concurrent use.
+type FakeBackend struct {
+	mu       sync.Mutex
+	fixtures map[string]Fixture
+	errs     map[string]error
+	pingErr  error
+}
+
+// NewFakeBackend returns a FakeBackend returning the results of the fixtures
+func NewFakeBackend(fixtures ...Fixture) *FakeBackend {
+	b := &FakeBackend{
+		fixtures: make(map[string]Fixture),
+		errs:     make(map[string]error),
+	}
+	for _, fixture := range fixtures {
+		b.fixtures[fixture.Request] = fixture
+	}
+	return b
+}
+
+// SetResult sets the columns and rows returned by the request of the fixture
+func (b *FakeBackend) SetResult(fixture Fixture) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fixtures[fixture.Request] = fixture
+	delete(b.errs, fixture.Request)
+}
+
+// SetError makes the request fail with err
+func (b *FakeBackend) SetError(request string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.errs[request] = err
+}
+
+// SetPingError makes pings fail with err, or succeed if err is nil
+func (b *FakeBackend) SetPingError(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pingErr = err
+}
+
+func (b *FakeBackend) Ping(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.pingErr
+}
+
+func (b *FakeBackend) Query(ctx context.Context, request string) ([]string, [][]string, error) {
+	fixture, err := b.fixture(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fixture.Columns, fixture.Rows, ctx.Err()
+}
+
+func (b *FakeBackend) Describe(ctx context.Context, request string) ([]string, error) {
+	fixture, err := b.fixture(request)
+	if err != nil {
+		return nil, err
+	}
+	return fixture.Columns, ctx.Err()
+}
+
+func (b *FakeBackend) Close() error {
+	return nil
+}
+
+// fixture returns the fixture of the request, or the error it was set to
+// fail with
+func (b *FakeBackend) fixture(request string) (Fixture, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err, ok := b.errs[request]; ok {
+		return Fixture{}, err
+	}
+	fixture, ok := b.fixtures[request]
+	if !ok {
+		return Fixture{}, errNoFixture
+	}
+	return fixture, nil
+}
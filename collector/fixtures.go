@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+// errNoFixture is returned when replaying a request whose results were not
+// recorded
+var errNoFixture = errors.New("no fixture recorded for the request")
+
+// Fixture holds the columns and rows returned by a request, recorded to be
+// replayed without database
+type Fixture struct {
+	Request string     `json:"request"`
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// Fixtures is the content of a fixtures file
+type Fixtures struct {
+	Fixtures []Fixture `json:"fixtures"`
+}
+
+// LoadFixtures reads the fixtures recorded in a yaml or json file
+func LoadFixtures(fixturesFile string) ([]Fixture, error) {
+	content, err := os.ReadFile(fixturesFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the fixtures file %s: %w", fixturesFile, err)
+	}
+	var fixtures Fixtures
+	if err := yaml.Unmarshal(content, &fixtures); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal the fixtures file %s: %w", fixturesFile, err)
+	}
+	for _, fixture := range fixtures.Fixtures {
+		for _, row := range fixture.Rows {
+			if len(row) != len(fixture.Columns) {
+				return nil, fmt.Errorf("fixture of request %q: row %v doesn't match columns %v", fixture.Request, row, fixture.Columns)
+			}
+		}
+	}
+	return fixtures.Fixtures, nil
+}
+
+// recordingBackend records the results of the requests run by its Backend
+type recordingBackend struct {
+	Backend
+	recorder *fixtureRecorder
+}
+
+func (b recordingBackend) Query(ctx context.Context, request string) ([]string, [][]string, error) {
+	columns, rows, err := b.Backend.Query(ctx, request)
+	// Only complete results are recorded
+	if err == nil {
+		if rows == nil {
+			rows = [][]string{}
+		}
+		b.recorder.record(Fixture{Request: request, Columns: columns, Rows: rows})
+	}
+	return columns, rows, err
+}
+
+// fixtureRecorder records the results of the requests to a fixtures file
+type fixtureRecorder struct {
+	mu       sync.Mutex
+	file     string
+	fixtures map[string]Fixture
+	changed  bool
+}
+
+func newFixtureRecorder(file string) *fixtureRecorder {
+	return &fixtureRecorder{file: file, fixtures: make(map[string]Fixture)}
+}
+
+// record keeps the latest result of the request
+func (r *fixtureRecorder) record(fixture Fixture) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if recorded, ok := r.fixtures[fixture.Request]; ok && reflect.DeepEqual(recorded, fixture) {
+		return
+	}
+	r.fixtures[fixture.Request] = fixture
+	r.changed = true
+}
+
+// save writes the recorded fixtures, sorted by request, if any was recorded
+// since the last save
+func (r *fixtureRecorder) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.changed {
+		return nil
+	}
+	var fixtures Fixtures
+	for _, fixture := range r.fixtures {
+		fixtures.Fixtures = append(fixtures.Fixtures, fixture)
+	}
+	sort.Slice(fixtures.Fixtures, func(i, j int) bool {
+		return fixtures.Fixtures[i].Request < fixtures.Fixtures[j].Request
+	})
+	content, err := yaml.Marshal(fixtures)
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomically(r.file, content); err != nil {
+		return fmt.Errorf("cannot write the fixtures file %s: %w", r.file, err)
+	}
+	r.changed = false
+	return nil
+}
+
+// WriteFileAtomically replaces the file by a temporary file of the same
+// directory, so that an interrupted write doesn't leave a truncated file and
+// readers such as the textfile collector of the node exporter never see a
+// partially written file
+func WriteFileAtomically(file string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
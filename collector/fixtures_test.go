@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+const replayedMetrics = `
+[[metric]]
+context = "sessions"
+labels = [ "status" ]
+metricsdesc = { value = "Number of sessions." }
+request = "SELECT status, COUNT(*) as value FROM v$session GROUP BY status"
+
+[[metric]]
+context = "activity"
+metricsdesc = { value = "Activity." }
+fieldtoappend = "name"
+request = "SELECT name, value FROM v$sysstat"
+`
+
+var replayedFixtures = []Fixture{{
+	Request: "SELECT status, COUNT(*) as value FROM v$session GROUP BY status",
+	Columns: []string{"STATUS", "VALUE"},
+	Rows:    [][]string{{"ACTIVE", "3"}, {"INACTIVE", "12"}},
+}, {
+	Request: "SELECT name, value FROM v$sysstat",
+	Columns: []string{"NAME", "VALUE"},
+	Rows:    [][]string{{"user commits", "42"}},
+}}
+
+// writeReplayedMetrics writes replayedMetrics to a metrics file
+func writeReplayedMetrics(t *testing.T) string {
+	metricsFile := filepath.Join(t.TempDir(), "metrics.toml")
+	assert.NoError(t, os.WriteFile(metricsFile, []byte(replayedMetrics), 0o644))
+	return metricsFile
+}
+
+func TestReplayFixtures(t *testing.T) {
+	metricsFile := writeReplayedMetrics(t)
+	fixturesFile := filepath.Join(t.TempDir(), "fixtures.yaml")
+	content, err := yaml.Marshal(Fixtures{Fixtures: replayedFixtures})
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(fixturesFile, content, 0o644))
+
+	e, err := NewExporter(log.NewNopLogger(), &Config{
+		DefaultMetricsFile: metricsFile,
+		QueryTimeout:       5,
+		ReplayFile:         fixturesFile,
+	})
+	assert.NoError(t, err)
+
+	expected := `
+# HELP oracledb_activity_user_commits Activity.
+# TYPE oracledb_activity_user_commits gauge
+oracledb_activity_user_commits 42
+# HELP oracledb_sessions_value Number of sessions.
+# TYPE oracledb_sessions_value gauge
+oracledb_sessions_value{status="ACTIVE"} 3
+oracledb_sessions_value{status="INACTIVE"} 12
+# HELP oracledb_up Whether the Oracle database server is up.
+# TYPE oracledb_up gauge
+oracledb_up 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(e, strings.NewReader(expected),
+		"oracledb_activity_user_commits", "oracledb_sessions_value", "oracledb_up"))
+}
+
+func TestRecordingBackend(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fixtures.yaml")
+	request := "SELECT status,\n  COUNT(*) as value\nFROM v$session GROUP BY status"
+	fixture := Fixture{
+		Request: request,
+		Columns: []string{"STATUS", "VALUE"},
+		Rows:    [][]string{{"ACTIVE", "3"}},
+	}
+	recorder := newFixtureRecorder(file)
+	backend := recordingBackend{Backend: NewFakeBackend(fixture), recorder: recorder}
+
+	_, _, err := backend.Query(context.Background(), request)
+	assert.NoError(t, err)
+	_, _, err = backend.Query(context.Background(), "SELECT 1 FROM dual")
+	assert.ErrorIs(t, err, errNoFixture)
+	assert.NoError(t, recorder.save())
+
+	fixtures, err := LoadFixtures(file)
+	assert.NoError(t, err)
+	assert.Equal(t, []Fixture{fixture}, fixtures)
+}
+
+func TestRecorderSavesChanges(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "fixtures.yaml")
+	fixture := Fixture{Request: "SELECT 1 as value FROM dual", Columns: []string{"VALUE"}, Rows: [][]string{{"1"}}}
+	recorder := newFixtureRecorder(file)
+	recorder.record(fixture)
+	assert.NoError(t, recorder.save())
+
+	// The same results are not written again
+	assert.NoError(t, os.Remove(file))
+	recorder.record(fixture)
+	assert.NoError(t, recorder.save())
+	assert.NoFileExists(t, file)
+
+	fixture.Rows = [][]string{{"2"}}
+	recorder.record(fixture)
+	assert.NoError(t, recorder.save())
+	fixtures, err := LoadFixtures(file)
+	assert.NoError(t, err)
+	assert.Equal(t, []Fixture{fixture}, fixtures)
+
+	// No temporary file is left
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cachedScrape holds the results of the last successful scrape of a metric
+type cachedScrape struct {
+	scrapedAt time.Time
+	results   []prometheus.Metric
+}
+
+// metricKey identifies a metric definition, as several definitions may
+// share the same context
+func metricKey(metric Metric) string {
+	return metric.Context + "\n" + metric.Request
+}
+
+// metricScrapeInterval returns the minimum interval between two scrapes of the
+// metric, zero meaning the metric is scraped on every scrape.
+func (e *Exporter) metricScrapeInterval(metric Metric) time.Duration {
+	if metric.ScrapeInterval == "" {
+		return 0
+	}
+	interval, err := time.ParseDuration(metric.ScrapeInterval)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "Unable to parse scrapeinterval, scraping on every scrape", "context", metric.Context, "scrapeinterval", metric.ScrapeInterval, "error", err)
+		return 0
+	}
+	return interval
+}
+
+// scrapeMetric scrapes the metric, unless its scrape interval has not
+// elapsed since its last successful scrape, in which case the results of
+// this scrape are sent again and cached is true.
+func (e *Exporter) scrapeMetric(ctx context.Context, ch chan<- prometheus.Metric, metric Metric) (cached bool, err error) {
+	interval := e.metricScrapeInterval(metric)
+	if interval == 0 {
+		return false, e.ScrapeMetricContext(ctx, e.backend, ch, metric)
+	}
+
+	key := metricKey(metric)
+	e.cacheMu.Lock()
+	previous, ok := e.metricCache[key]
+	e.cacheMu.Unlock()
+	if ok && time.Since(previous.scrapedAt) < interval {
+		level.Debug(e.logger).Log("msg", "Serving cached results", "context", metric.Context, "age", time.Since(previous.scrapedAt))
+		for _, result := range previous.results {
+			ch <- result
+		}
+		return true, nil
+	}
+
+	scrapeStart := time.Now()
+	resultCh := make(chan prometheus.Metric)
+	doneCh := make(chan struct{})
+	var results []prometheus.Metric
+	go func() {
+		for result := range resultCh {
+			results = append(results, result)
+			ch <- result
+		}
+		close(doneCh)
+	}()
+	err = e.ScrapeMetricContext(ctx, e.backend, resultCh, metric)
+	close(resultCh)
+	<-doneCh
+	if err != nil {
+		return false, err
+	}
+
+	e.cacheMu.Lock()
+	e.metricCache[key] = cachedScrape{scrapedAt: scrapeStart, results: results}
+	e.cacheMu.Unlock()
+	return false, nil
+}
+
+// resetMetricCache drops the cached results of all metrics
+func (e *Exporter) resetMetricCache() {
+	e.cacheMu.Lock()
+	e.metricCache = make(map[string]cachedScrape)
+	e.cacheMu.Unlock()
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrapeIntervalServesCachedResults(t *testing.T) {
+	metrics := []Metric{{
+		Context:        "cached",
+		MetricsDesc:    map[string]string{"value": "Cached value."},
+		Request:        "SELECT 1 as value FROM dual",
+		ScrapeInterval: "1h",
+	}, {
+		Context:     "live",
+		MetricsDesc: map[string]string{"value": "Live value."},
+		Request:     "SELECT 2 as value FROM dual",
+	}}
+	e, backend := fakeExporter(Config{}, metrics,
+		Fixture{Request: metrics[0].Request, Columns: []string{"VALUE"}, Rows: [][]string{{"1"}}},
+		Fixture{Request: metrics[1].Request, Columns: []string{"VALUE"}, Rows: [][]string{{"2"}}},
+	)
+	expected := func(cached, live int) *strings.Reader {
+		return strings.NewReader(fmt.Sprintf(`
+# HELP oracledb_cached_value Cached value.
+# TYPE oracledb_cached_value gauge
+oracledb_cached_value %d
+# HELP oracledb_live_value Live value.
+# TYPE oracledb_live_value gauge
+oracledb_live_value %d
+`, cached, live))
+	}
+	names := []string{"oracledb_cached_value", "oracledb_live_value"}
+	assert.NoError(t, testutil.CollectAndCompare(e, expected(1, 2), names...))
+
+	// Only the metric without scrape interval is scraped again
+	backend.SetResult(Fixture{Request: metrics[0].Request, Columns: []string{"VALUE"}, Rows: [][]string{{"10"}}})
+	backend.SetResult(Fixture{Request: metrics[1].Request, Columns: []string{"VALUE"}, Rows: [][]string{{"20"}}})
+	assert.NoError(t, testutil.CollectAndCompare(e, expected(1, 20), names...))
+
+	// The cached results are dropped when the metrics change
+	e.useMetrics(e.fileMetrics)
+	assert.NoError(t, testutil.CollectAndCompare(e, expected(10, 20), names...))
+}
+
+func TestCachedResultsKeepTheirScrapeTime(t *testing.T) {
+	metric, fixture := valueMetric("cached", "1")
+	metric.ScrapeInterval = "1h"
+	e, _ := fakeExporter(Config{}, []Metric{metric}, fixture)
+	collector := scrapeCollector{exporter: e}
+
+	testutil.CollectAndCount(collector)
+	scrapedAt := testutil.ToFloat64(e.lastSuccess.WithLabelValues("cached"))
+	duration := testutil.ToFloat64(e.scrapeDuration.WithLabelValues("cached"))
+	time.Sleep(20 * time.Millisecond)
+	testutil.CollectAndCount(collector)
+	assert.Equal(t, scrapedAt, testutil.ToFloat64(e.lastSuccess.WithLabelValues("cached")))
+	assert.Equal(t, duration, testutil.ToFloat64(e.scrapeDuration.WithLabelValues("cached")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(e.scrapeSuccess.WithLabelValues("cached")))
+}
@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// metricsFileExtensions are the extensions of the files loaded from the
+// directories given as custom metrics
+var metricsFileExtensions = []string{".toml", ".yaml", ".yml"}
+
+// isMetricsFile tells whether the file is loaded when found in a directory
+func isMetricsFile(name string) bool {
+	base := filepath.Base(name)
+	if strings.HasPrefix(base, ".") {
+		return false
+	}
+	for _, extension := range metricsFileExtensions {
+		if strings.HasSuffix(base, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGlob tells whether the path is a glob pattern
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// expandMetricsPath returns the metrics files designated by path, which is
+// either a file, a directory or a glob pattern, in lexical order.
+func expandMetricsPath(path string) ([]string, error) {
+	if isGlob(path) {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metrics files pattern %s: %w", path, err)
+		}
+		// Like in directories, subdirectories and hidden files are skipped,
+		// such as the ..data directory of Kubernetes ConfigMap volumes
+		var files []string
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() && isMetricsFile(match) {
+				files = append(files, match)
+			}
+		}
+		sort.Strings(files)
+		return files, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		// Errors are reported when loading the file
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the metrics directory %s: %w", path, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && isMetricsFile(entry.Name()) {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// matchesMetricsPath tells whether file is, or could be, one of the metrics
+// files designated by path
+func matchesMetricsPath(path, file string) bool {
+	path = filepath.Clean(path)
+	file = filepath.Clean(file)
+	if isGlob(path) {
+		matched, _ := filepath.Match(path, file)
+		return matched && isMetricsFile(file)
+	}
+	if file == path {
+		return true
+	}
+	return filepath.Dir(file) == path && isMetricsFile(file)
+}
+
+// watchedDirs returns the directories to watch for changes of the metrics
+// files designated by path. When the directory part of a glob pattern has
+// wildcards, the directories matching it when called are returned.
+func watchedDirs(path string) []string {
+	path = filepath.Clean(path)
+	if !isGlob(path) {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return []string{path}
+		}
+	}
+	dir := filepath.Dir(path)
+	if !isGlob(dir) {
+		return []string{dir}
+	}
+	matches, _ := filepath.Glob(dir)
+	var dirs []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.IsDir() {
+			dirs = append(dirs, match)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// CustomMetricsFiles returns the custom metrics files of the configuration,
+// in the order of Config.CustomMetrics, with directories and glob patterns
+// expanded
+func (c *Config) CustomMetricsFiles() ([]string, error) {
+	var files []string
+	for _, customMetrics := range strings.Split(c.CustomMetrics, ",") {
+		if customMetrics == "" {
+			continue
+		}
+		expanded, err := expandMetricsPath(customMetrics)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, expanded...)
+	}
+	return files, nil
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomMetricsFilesExpandsDirectoriesAndGlobs(t *testing.T) {
+	dir := t.TempDir()
+	confd := filepath.Join(dir, "conf.d")
+	assert.NoError(t, os.Mkdir(confd, 0700))
+	for _, name := range []string{"b.yaml", "a.toml", "c.yml", "README.md", ".hidden.toml"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(confd, name), []byte(""), 0600))
+	}
+	for _, name := range []string{"team2.toml", "team1.toml", "team1.yaml"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(""), 0600))
+	}
+
+	config := &Config{
+		CustomMetrics: filepath.Join(dir, "single.toml") + "," + confd + "," + filepath.Join(dir, "team*.toml"),
+	}
+	files, err := config.CustomMetricsFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "single.toml"),
+		filepath.Join(confd, "a.toml"),
+		filepath.Join(confd, "b.yaml"),
+		filepath.Join(confd, "c.yml"),
+		filepath.Join(dir, "team1.toml"),
+		filepath.Join(dir, "team2.toml"),
+	}, files)
+
+	assert.True(t, matchesMetricsPath(confd, filepath.Join(confd, "new.toml")))
+	assert.False(t, matchesMetricsPath(confd, filepath.Join(confd, "README.md")))
+	assert.True(t, matchesMetricsPath(filepath.Join(dir, "team*.toml"), filepath.Join(dir, "team3.toml")))
+	assert.False(t, matchesMetricsPath(filepath.Join(dir, "team*.toml"), filepath.Join(dir, "team3.yaml")))
+}
+
+func TestCustomMetricsFilesSkipsGlobDirectories(t *testing.T) {
+	// Layout of a Kubernetes ConfigMap volume
+	cm := t.TempDir()
+	data := filepath.Join(cm, "..2024_01_01_00_00_00.000000000")
+	assert.NoError(t, os.Mkdir(data, 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(data, "metrics.toml"), []byte(""), 0600))
+	assert.NoError(t, os.Symlink(filepath.Base(data), filepath.Join(cm, "..data")))
+	assert.NoError(t, os.Symlink(filepath.Join("..data", "metrics.toml"), filepath.Join(cm, "metrics.toml")))
+
+	config := &Config{CustomMetrics: filepath.Join(cm, "*")}
+	files, err := config.CustomMetricsFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(cm, "metrics.toml")}, files)
+	assert.False(t, matchesMetricsPath(filepath.Join(cm, "*"), filepath.Join(cm, "..data")))
+}
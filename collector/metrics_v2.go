@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// MetricsV2 is the content of a metrics file in the v2 format, where each
+// query lists its metrics with their own settings
+type MetricsV2 struct {
+	Version int       `toml:"version" json:"version"`
+	Query   []QueryV2 `toml:"query" json:"queries"`
+}
+
+// QueryV2 is a request and the metrics built from the rows it returns
+type QueryV2 struct {
+	Context          string     `toml:"context" json:"context"`
+	Request          Request    `toml:"request" json:"request"`
+	FieldToAppend    string     `toml:"fieldtoappend,omitempty" json:"fieldtoappend,omitempty"`
+	IgnoreZeroResult bool       `toml:"ignorezeroresult,omitempty" json:"ignorezeroresult,omitempty"`
+	ScrapeInterval   string     `toml:"scrapeinterval,omitempty" json:"scrapeinterval,omitempty"`
+	QueryTimeout     string     `toml:"querytimeout,omitempty" json:"querytimeout,omitempty"`
+	Priority         int        `toml:"priority,omitzero" json:"priority,omitempty"`
+	Metric           []MetricV2 `toml:"metric" json:"metrics"`
+}
+
+// MetricV2 is a metric built from a column of the rows returned by a query.
+// Its name is prefixed by the namespace and the context of the query, and
+// suffixed by its unit.
+type MetricV2 struct {
+	Name    string            `toml:"name" json:"name"`
+	Column  string            `toml:"column,omitempty" json:"column,omitempty"`
+	Type    string            `toml:"type,omitempty" json:"type,omitempty"`
+	Help    string            `toml:"help" json:"help"`
+	Labels  []string          `toml:"labels,omitempty" json:"labels,omitempty"`
+	Unit    string            `toml:"unit,omitempty" json:"unit,omitempty"`
+	Buckets map[string]string `toml:"buckets,omitempty" json:"buckets,omitempty"`
+}
+
+// Request is the request of a query, written as a multi-line string in toml
+// files when it spans several lines
+type Request string
+
+// MarshalTOML implements toml.Marshaler
+func (r Request) MarshalTOML() ([]byte, error) {
+	request := string(r)
+	if !strings.Contains(request, "\n") || strings.Contains(request, "'''") {
+		return []byte(fmt.Sprintf("%q", request)), nil
+	}
+	return []byte("'''\n" + strings.TrimPrefix(request, "\n") + "'''"), nil
+}
+
+// metricsVersion is decoded first to tell the format of a metrics file
+type metricsVersion struct {
+	Version int `toml:"version" json:"version"`
+}
+
+// metricsV1 is the content of a metrics file in the v1 format, whose version
+// is optional
+type metricsV1 struct {
+	Version int      `toml:"version" json:"version"`
+	Metric  []Metric `toml:"metric" json:"metrics"`
+}
+
+// Metrics converts the queries to metric definitions
+func (m MetricsV2) Metrics() (Metrics, error) {
+	var metrics Metrics
+	var errs []error
+	for _, query := range m.Query {
+		metric, err := query.Definition()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("query %s: %w", query.Context, err))
+			continue
+		}
+		metrics.Metric = append(metrics.Metric, metric)
+	}
+	return metrics, errors.Join(errs...)
+}
+
+// Definition converts the query to a metric definition
+func (q QueryV2) Definition() (Metric, error) {
+	metric := Metric{
+		Context:          q.Context,
+		Request:          string(q.Request),
+		FieldToAppend:    q.FieldToAppend,
+		IgnoreZeroResult: q.IgnoreZeroResult,
+		ScrapeInterval:   q.ScrapeInterval,
+		QueryTimeout:     q.QueryTimeout,
+		Priority:         q.Priority,
+		MetricsDesc:      make(map[string]string),
+	}
+	if len(q.Metric) == 0 {
+		return Metric{}, errors.New("no metrics")
+	}
+	// Labels common to all the metrics are those of the definition
+	sameLabels := true
+	for _, m := range q.Metric[1:] {
+		sameLabels = sameLabels && reflect.DeepEqual(m.Labels, q.Metric[0].Labels)
+	}
+	if sameLabels {
+		metric.Labels = q.Metric[0].Labels
+	}
+
+	for _, m := range q.Metric {
+		if m.Name == "" {
+			return Metric{}, errors.New("metric name is missing")
+		}
+		// The column is named after the metric, without its unit
+		name, column := m.Name, m.Name
+		if m.Column != "" {
+			column = m.Column
+		}
+		if m.Unit != "" && !strings.HasSuffix(name, "_"+m.Unit) {
+			name += "_" + m.Unit
+		}
+		if _, ok := metric.MetricsDesc[name]; ok {
+			return Metric{}, fmt.Errorf("duplicated metric %s", name)
+		}
+		metric.MetricsDesc[name] = m.Help
+		if column != name {
+			if metric.columns == nil {
+				metric.columns = make(map[string]string)
+			}
+			metric.columns[name] = column
+		}
+		if !sameLabels {
+			if metric.metricsLabels == nil {
+				metric.metricsLabels = make(map[string][]string)
+			}
+			metric.metricsLabels[name] = m.Labels
+		}
+		if m.Type != "" {
+			if metric.MetricsType == nil {
+				metric.MetricsType = make(map[string]string)
+			}
+			metric.MetricsType[name] = m.Type
+		}
+		if len(m.Buckets) > 0 {
+			if metric.MetricsBuckets == nil {
+				metric.MetricsBuckets = make(map[string]map[string]string)
+			}
+			metric.MetricsBuckets[name] = m.Buckets
+		}
+	}
+	return metric, nil
+}
+
+// ConvertMetrics converts metric definitions to the v2 format
+func ConvertMetrics(metrics Metrics) MetricsV2 {
+	converted := MetricsV2{Version: 2}
+	for _, metric := range metrics.Metric {
+		query := QueryV2{
+			Context:          metric.Context,
+			Request:          Request(metric.Request),
+			FieldToAppend:    metric.FieldToAppend,
+			IgnoreZeroResult: metric.IgnoreZeroResult,
+			ScrapeInterval:   metric.ScrapeInterval,
+			QueryTimeout:     metric.QueryTimeout,
+			Priority:         metric.Priority,
+		}
+		names := make([]string, 0, len(metric.MetricsDesc))
+		for name := range metric.MetricsDesc {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			m := MetricV2{
+				Name:    name,
+				Type:    strings.ToLower(metric.MetricsType[name]),
+				Help:    metric.MetricsDesc[name],
+				Labels:  metric.labels(name),
+				Buckets: metric.MetricsBuckets[name],
+			}
+			if column := metric.column(name); column != name {
+				m.Column = column
+			}
+			query.Metric = append(query.Metric, m)
+		}
+		converted.Query = append(converted.Query, query)
+	}
+	return converted
+}
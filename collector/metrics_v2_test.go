@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/yaml"
+)
+
+const v2Metrics = `
+version = 2
+
+[[query]]
+context = "tablespace"
+request = "SELECT tablespace, type, bytes, max_bytes FROM dba_tablespace_usage"
+
+[[query.metric]]
+name = "used"
+column = "bytes"
+unit = "bytes"
+help = "Used bytes of the tablespace."
+labels = ["tablespace", "type"]
+
+[[query.metric]]
+name = "max_bytes"
+unit = "bytes"
+help = "Maximum size of the tablespace."
+labels = ["tablespace"]
+`
+
+func TestLoadMetricsFileConvertsV2(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "metrics.toml")
+	assert.NoError(t, os.WriteFile(file, []byte(v2Metrics), 0o644))
+
+	metrics, unknown, err := LoadMetricsFile(file)
+	assert.NoError(t, err)
+	assert.Empty(t, unknown)
+	assert.NoError(t, ValidateMetrics(metrics))
+	assert.Len(t, metrics.Metric, 1)
+	metric := metrics.Metric[0]
+	assert.Equal(t, map[string]string{
+		"used_bytes": "Used bytes of the tablespace.",
+		"max_bytes":  "Maximum size of the tablespace.",
+	}, metric.MetricsDesc)
+	assert.Equal(t, "bytes", metric.column("used_bytes"))
+	assert.Equal(t, "max_bytes", metric.column("max_bytes"))
+	assert.Equal(t, []string{"tablespace", "type"}, metric.labels("used_bytes"))
+	assert.Equal(t, []string{"tablespace"}, metric.labels("max_bytes"))
+}
+
+func TestConvertMetricsRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{
+		"../default-metrics.toml",
+		"../default-metrics.yaml",
+		"../custom-metrics-example/custom-metrics.toml",
+		"../custom-metrics-example/metric-histogram-example.toml",
+	} {
+		metrics, _, err := LoadMetricsFile(file)
+		assert.NoError(t, err, file)
+
+		converted := ConvertMetrics(metrics)
+		var buffer bytes.Buffer
+		assert.NoError(t, toml.NewEncoder(&buffer).Encode(converted), file)
+		tomlFile := filepath.Join(dir, "converted.toml")
+		assert.NoError(t, os.WriteFile(tomlFile, buffer.Bytes(), 0o644))
+		content, err := yaml.Marshal(converted)
+		assert.NoError(t, err, file)
+		yamlFile := filepath.Join(dir, "converted.yaml")
+		assert.NoError(t, os.WriteFile(yamlFile, content, 0o644))
+
+		for _, convertedFile := range []string{tomlFile, yamlFile} {
+			reloaded, unknown, err := LoadMetricsFile(convertedFile)
+			assert.NoError(t, err, file)
+			assert.Empty(t, unknown, file)
+			assert.Equal(t, metrics, reloaded, file)
+		}
+	}
+}
+
+func TestDefinitionColumnDefaultsToName(t *testing.T) {
+	metric, err := QueryV2{
+		Context: "tablespace",
+		Request: "SELECT tablespace, free FROM dba_tablespace_usage",
+		Metric:  []MetricV2{{Name: "free", Unit: "bytes", Help: "Free bytes of the tablespace."}},
+	}.Definition()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"free_bytes": "Free bytes of the tablespace."}, metric.MetricsDesc)
+	assert.Equal(t, "free", metric.column("free_bytes"))
+}
@@ -0,0 +1,157 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+)
+
+// Option configures the exporter created by New
+type Option func(*options)
+
+type options struct {
+	config  Config
+	logger  log.Logger
+	backend Backend
+	// fallback is set by NewExporter
+	fallback bool
+}
+
+// WithConfig sets all the settings of the exporter. The options following it
+// override its settings.
+func WithConfig(config Config) Option {
+	return func(o *options) {
+		o.config = config
+	}
+}
+
+// WithDSN sets the data source name of the database
+func WithDSN(dsn string) Option {
+	return func(o *options) {
+		o.config.DSN = dsn
+	}
+}
+
+// WithLogger sets the logger of the exporter, which logs nothing by default
+func WithLogger(logger log.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithDefaultMetrics sets the default metrics file, replacing the embedded
+// default metrics
+func WithDefaultMetrics(file string) Option {
+	return func(o *options) {
+		o.config.DefaultMetricsFile = file
+	}
+}
+
+// WithCustomMetrics adds custom metrics files, directories or glob patterns
+func WithCustomMetrics(paths ...string) Option {
+	return func(o *options) {
+		if o.config.CustomMetrics != "" {
+			paths = append([]string{o.config.CustomMetrics}, paths...)
+		}
+		o.config.CustomMetrics = strings.Join(paths, ",")
+	}
+}
+
+// WithPool sets the maximum numbers of idle and open connections to the
+// database
+func WithPool(maxIdleConns, maxOpenConns int) Option {
+	return func(o *options) {
+		o.config.MaxIdleConns = maxIdleConns
+		o.config.MaxOpenConns = maxOpenConns
+	}
+}
+
+// WithQueryTimeout sets the default timeout of the requests, rounded up to
+// the second
+func WithQueryTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.config.QueryTimeout = int((timeout + time.Second - 1) / time.Second)
+	}
+}
+
+// WithScrapeMinInterval sets the minimum interval between two scrapes of the
+// database, the results of the previous scrape being served in between
+func WithScrapeMinInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.config.ScrapeMinInterval = interval
+	}
+}
+
+// WithBackend runs the requests through the given backend rather than
+// connecting to the database, such as a FakeBackend in tests
+func WithBackend(backend Backend) Option {
+	return func(o *options) {
+		o.backend = backend
+	}
+}
+
+// withFallback makes New use the default metrics when the metrics files cannot
+// be loaded, and return the exporter along with the connection error, if any,
+// without pinging the database
+func withFallback() Option {
+	return func(o *options) {
+		o.fallback = true
+	}
+}
+
+// New creates an exporter configured by the options, on top of the default
+// configuration. It fails if the metrics files cannot be loaded, or if the
+// database cannot be reached before ctx is done. The exporter must be closed
+// once no longer used.
+func New(ctx context.Context, opts ...Option) (*Exporter, error) {
+	o := options{config: *CreateDefaultConfig(), logger: log.NewNopLogger()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var e *Exporter
+	if o.fallback {
+		e = newExporter(o.logger, &o.config)
+	} else {
+		e = buildExporter(o.logger, &o.config)
+		if err := e.reloadMetrics(); err != nil {
+			return nil, err
+		}
+	}
+	err := e.open(o.backend)
+	if o.fallback {
+		return e, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := e.backend.Ping(ctx); err != nil {
+		e.backend.Close()
+		return nil, fmt.Errorf("cannot reach the database %s: %w", maskDsn(e.dsn), err)
+	}
+	return e, nil
+}
+
+// Close waits for the running scrape, if any, and closes the connections to
+// the database
+func (e *Exporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	if e.backend == nil {
+		return nil
+	}
+	return e.backend.Close()
+}
+
+// Metrics returns the metric definitions currently scraped
+func (e *Exporter) Metrics() Metrics {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	metrics := Metrics{Metric: make([]Metric, len(e.metricsToScrape.Metric))}
+	copy(metrics.Metric, e.metricsToScrape.Metric)
+	return metrics
+}
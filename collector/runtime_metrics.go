@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AddMetric adds a metric definition, replacing the one previously added
+// with the same context, if any. The definitions added replace those of the
+// metrics files with the same context, including after a reload. The
+// definition is validated as by the check-config command, the metrics being
+// left unchanged when it is invalid.
+func (e *Exporter) AddMetric(metric Metric) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	runtimeMetrics := make([]Metric, 0, len(e.runtimeMetrics)+1)
+	replaced := false
+	for _, m := range e.runtimeMetrics {
+		if m.Context == metric.Context {
+			m, replaced = metric, true
+		}
+		runtimeMetrics = append(runtimeMetrics, m)
+	}
+	if !replaced {
+		runtimeMetrics = append(runtimeMetrics, metric)
+	}
+	return e.setRuntimeMetrics(runtimeMetrics)
+}
+
+// RemoveMetric removes the metric definition added with the context. The
+// definitions of the metrics files with the same context, if any, are scraped
+// again.
+func (e *Exporter) RemoveMetric(context string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	runtimeMetrics := make([]Metric, 0, len(e.runtimeMetrics))
+	for _, m := range e.runtimeMetrics {
+		if m.Context != context {
+			runtimeMetrics = append(runtimeMetrics, m)
+		}
+	}
+	if len(runtimeMetrics) == len(e.runtimeMetrics) {
+		return fmt.Errorf("no metric added with context %s", context)
+	}
+	return e.setRuntimeMetrics(runtimeMetrics)
+}
+
+// SetMetrics replaces all the metric definitions added, each context being
+// defined at most once. They are validated as by AddMetric.
+func (e *Exporter) SetMetrics(metrics Metrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	runtimeMetrics := make([]Metric, len(metrics.Metric))
+	copy(runtimeMetrics, metrics.Metric)
+	return e.setRuntimeMetrics(runtimeMetrics)
+}
+
+// setRuntimeMetrics validates the metric definitions and scrapes them along
+// with those of the metrics files
+func (e *Exporter) setRuntimeMetrics(runtimeMetrics []Metric) error {
+	var errs []error
+	contexts := make(map[string]bool)
+	for _, m := range runtimeMetrics {
+		if m.Context == "" {
+			errs = append(errs, errors.New("metric context is missing"))
+			continue
+		}
+		if contexts[m.Context] {
+			errs = append(errs, fmt.Errorf("metric %s: context defined twice", m.Context))
+		}
+		contexts[m.Context] = true
+		if err := m.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("metric %s: %w", m.Context, err))
+		}
+	}
+	metrics := mergeMetrics(e.fileMetrics, runtimeMetrics)
+	if err := CheckConsistency(sharingNames(metrics, runtimeMetrics)); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	e.runtimeMetrics = runtimeMetrics
+	e.useMetrics(metrics)
+	return nil
+}
+
+// mergeMetrics returns the metrics of the files, those with the context of a
+// runtime metric being replaced by the runtime metrics
+func mergeMetrics(fileMetrics Metrics, runtimeMetrics []Metric) Metrics {
+	contexts := make(map[string]bool)
+	for _, m := range runtimeMetrics {
+		contexts[m.Context] = true
+	}
+	var metrics Metrics
+	for _, m := range fileMetrics.Metric {
+		if !contexts[m.Context] {
+			metrics.Metric = append(metrics.Metric, m)
+		}
+	}
+	metrics.Metric = append(metrics.Metric, runtimeMetrics...)
+	return metrics
+}
+
+// sharingNames returns the metrics defining a metric name also defined by the
+// runtime metrics, so that their consistency is checked without reporting
+// the inconsistencies of the metrics files alone
+func sharingNames(metrics Metrics, runtimeMetrics []Metric) Metrics {
+	names := make(map[string]bool)
+	for _, m := range runtimeMetrics {
+		for _, name := range metricNames(m) {
+			names[name] = true
+		}
+	}
+	var sharing Metrics
+	for _, m := range metrics.Metric {
+		for _, name := range metricNames(m) {
+			if names[name] {
+				sharing.Metric = append(sharing.Metric, m)
+				break
+			}
+		}
+	}
+	return sharing
+}
+
+// metricNames returns the names of the metrics of the definition, unless they
+// depend on the data
+func metricNames(m Metric) []string {
+	if m.FieldToAppend != "" {
+		return nil
+	}
+	names := make([]string, 0, len(m.MetricsDesc))
+	for metric := range m.MetricsDesc {
+		names = append(names, prometheus.BuildFQName(namespace, m.Context, metric))
+	}
+	return names
+}
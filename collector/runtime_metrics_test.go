@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func runtimeMetricsExporter(t *testing.T) *Exporter {
+	backend := NewFakeBackend(replayedFixtures...)
+	backend.SetResult(Fixture{
+		Request: "SELECT COUNT(*) as count FROM v$process",
+		Columns: []string{"COUNT"},
+		Rows:    [][]string{{"85"}},
+	})
+	backend.SetResult(Fixture{
+		Request: "SELECT status, COUNT(*) as sessions FROM v$session GROUP BY status",
+		Columns: []string{"STATUS", "SESSIONS"},
+		Rows:    [][]string{{"ACTIVE", "4"}},
+	})
+	e := newExporter(log.NewNopLogger(), &Config{DefaultMetricsFile: writeReplayedMetrics(t), QueryTimeout: 5})
+	e.backend = backend
+	return e
+}
+
+func metricContexts(e *Exporter) []string {
+	var contexts []string
+	for _, metric := range e.Metrics().Metric {
+		contexts = append(contexts, metric.Context)
+	}
+	return contexts
+}
+
+var processMetric = Metric{
+	Context:     "process",
+	MetricsDesc: map[string]string{"count": "Number of processes."},
+	Request:     "SELECT COUNT(*) as count FROM v$process",
+}
+
+func TestAddMetric(t *testing.T) {
+	e := runtimeMetricsExporter(t)
+	assert.NoError(t, e.AddMetric(processMetric))
+	assert.Equal(t, []string{"sessions", "activity", "process"}, metricContexts(e))
+
+	// The metric of the files with the same context is replaced
+	assert.NoError(t, e.AddMetric(Metric{
+		Context:     "sessions",
+		Labels:      []string{"status"},
+		MetricsDesc: map[string]string{"sessions": "Number of sessions by status."},
+		Request:     "SELECT status, COUNT(*) as sessions FROM v$session GROUP BY status",
+	}))
+	assert.Equal(t, []string{"activity", "process", "sessions"}, metricContexts(e))
+
+	expected := `
+# HELP oracledb_process_count Number of processes.
+# TYPE oracledb_process_count gauge
+oracledb_process_count 85
+# HELP oracledb_sessions_sessions Number of sessions by status.
+# TYPE oracledb_sessions_sessions gauge
+oracledb_sessions_sessions{status="ACTIVE"} 4
+`
+	names := []string{"oracledb_process_count", "oracledb_sessions_sessions", "oracledb_sessions_value"}
+	assert.NoError(t, testutil.CollectAndCompare(e, strings.NewReader(expected), names...))
+
+	// The metrics added are kept when reloading the metrics files
+	assert.NoError(t, e.Reload())
+	assert.Equal(t, []string{"activity", "process", "sessions"}, metricContexts(e))
+
+	// The metric of the files is scraped again once the metric is removed
+	assert.NoError(t, e.RemoveMetric("sessions"))
+	assert.Equal(t, []string{"sessions", "activity", "process"}, metricContexts(e))
+	assert.ErrorContains(t, e.RemoveMetric("sessions"), "no metric added with context sessions")
+}
+
+func TestAddInvalidMetric(t *testing.T) {
+	e := runtimeMetricsExporter(t)
+	for _, test := range []struct {
+		metric   Metric
+		expected string
+	}{
+		{
+			metric:   Metric{MetricsDesc: map[string]string{"count": "Number of processes."}, Request: "SELECT 1 as count FROM dual"},
+			expected: "metric context is missing",
+		},
+		{
+			metric:   Metric{Context: "process", MetricsDesc: map[string]string{"count": "Number of processes."}},
+			expected: "metric process: request is missing",
+		},
+		{
+			metric: Metric{
+				Context:     "sessions",
+				MetricsDesc: map[string]string{"sessions": "Number of sessions."},
+				Request:     "SELECT COUNT(*) as total FROM v$session",
+			},
+			expected: "column sessions is not returned by the request",
+		},
+	} {
+		assert.ErrorContains(t, e.AddMetric(test.metric), test.expected)
+	}
+	assert.Equal(t, []string{"sessions", "activity"}, metricContexts(e))
+}
+
+func TestSetMetrics(t *testing.T) {
+	e := runtimeMetricsExporter(t)
+	assert.NoError(t, e.SetMetrics(Metrics{Metric: []Metric{processMetric}}))
+	assert.Equal(t, []string{"sessions", "activity", "process"}, metricContexts(e))
+
+	err := e.SetMetrics(Metrics{Metric: []Metric{processMetric, processMetric}})
+	assert.ErrorContains(t, err, "metric process: context defined twice")
+
+	// Metrics sharing the same name must be consistent
+	err = e.SetMetrics(Metrics{Metric: []Metric{{
+		Context:     "a",
+		MetricsDesc: map[string]string{"b_c": "First."},
+		Request:     "SELECT 1 as b_c FROM dual",
+	}, {
+		Context:     "a_b",
+		MetricsDesc: map[string]string{"c": "Second."},
+		Request:     "SELECT 1 as c FROM dual",
+	}}})
+	assert.ErrorContains(t, err, `metric oracledb_a_b_c is defined with different help`)
+	assert.Equal(t, []string{"sessions", "activity", "process"}, metricContexts(e))
+
+	assert.NoError(t, e.SetMetrics(Metrics{}))
+	assert.Equal(t, []string{"sessions", "activity"}, metricContexts(e))
+}
+
+func TestAddMetricDuringCollect(t *testing.T) {
+	e := runtimeMetricsExporter(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				testutil.CollectAndCount(e)
+			}
+		}()
+	}
+	for j := 0; j < 20; j++ {
+		assert.NoError(t, e.AddMetric(processMetric))
+		assert.NoError(t, e.RemoveMetric(processMetric.Context))
+	}
+	wg.Wait()
+}
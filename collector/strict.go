@@ -0,0 +1,161 @@
+package collector
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// UnknownKey is a key of a metrics file which doesn't match any setting, such
+// as a misspelled one
+type UnknownKey struct {
+	File string
+	Line int
+	Key  string
+}
+
+func (k UnknownKey) String() string {
+	if k.Line == 0 {
+		return fmt.Sprintf("%s: unknown key %s", k.File, k.Key)
+	}
+	return fmt.Sprintf("%s:%d: unknown key %s", k.File, k.Line, k.Key)
+}
+
+// UnknownKeysError is returned when loading metrics files with unknown keys
+// in strict mode
+type UnknownKeysError []UnknownKey
+
+func (e UnknownKeysError) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, key := range e {
+		lines = append(lines, key.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tomlUnknownKeys returns the keys of the toml metrics file which were not
+// decoded. The keys nested in an unknown key are not reported.
+func tomlUnknownKeys(file, content string, md toml.MetaData) []UnknownKey {
+	lines := tomlKeyLines(content)
+	var unknown []UnknownKey
+	for _, key := range md.Undecoded() {
+		name := key.String()
+		if len(unknown) > 0 && strings.HasPrefix(name, unknown[len(unknown)-1].Key+".") {
+			continue
+		}
+		var line int
+		if len(lines[name]) > 0 {
+			line, lines[name] = lines[name][0], lines[name][1:]
+		}
+		unknown = append(unknown, UnknownKey{File: file, Line: line, Key: name})
+	}
+	return unknown
+}
+
+// tomlKeyLines returns the lines where each key of the toml document is
+// defined, in order of appearance. Only table headers and key/value pairs are
+// considered, which is enough to locate the keys of metrics files.
+func tomlKeyLines(content string) map[string][]int {
+	lines := make(map[string][]int)
+	var table, multiline string
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if multiline != "" {
+			if strings.Count(line, multiline)%2 == 1 {
+				multiline = ""
+			}
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if end := strings.LastIndex(line, "]"); end > 0 {
+				line = line[:end]
+			}
+			table = strings.TrimSpace(strings.Trim(line, "[]"))
+			lines[table] = append(lines[table], i+1)
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		if table != "" {
+			key = table + "." + key
+		}
+		lines[key] = append(lines[key], i+1)
+		for _, delimiter := range []string{`"""`, `'''`} {
+			if strings.Count(value, delimiter)%2 == 1 {
+				multiline = delimiter
+			}
+		}
+	}
+	return lines
+}
+
+// yamlUnknownKeys returns the keys of the yaml metrics file which don't match
+// any field of the value it is decoded to. Like sigs.k8s.io/yaml, the keys
+// are matched against the json names of the fields, ignoring case.
+func yamlUnknownKeys(file string, content []byte, decoded interface{}) ([]UnknownKey, error) {
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal the metrics config %s: %w", file, err)
+	}
+	var unknown []UnknownKey
+	for _, node := range document.Content {
+		unknown = append(unknown, yamlNodeUnknownKeys(file, node, reflect.TypeOf(decoded), "")...)
+	}
+	return unknown, nil
+}
+
+func yamlNodeUnknownKeys(file string, node *yamlv3.Node, t reflect.Type, path string) []UnknownKey {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var unknown []UnknownKey
+	switch {
+	case t.Kind() == reflect.Slice && node.Kind == yamlv3.SequenceNode:
+		for _, item := range node.Content {
+			unknown = append(unknown, yamlNodeUnknownKeys(file, item, t.Elem(), path)...)
+		}
+	case t.Kind() == reflect.Struct && node.Kind == yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			name := key.Value
+			if path != "" {
+				name = path + "." + key.Value
+			}
+			field, ok := jsonField(t, key.Value)
+			if !ok {
+				unknown = append(unknown, UnknownKey{File: file, Line: key.Line, Key: name})
+				continue
+			}
+			unknown = append(unknown, yamlNodeUnknownKeys(file, value, field.Type, name)...)
+		}
+	}
+	return unknown
+}
+
+// jsonField returns the field of the struct type decoded from the given json
+// key, as encoding/json would
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const misspelledTomlMetrics = `
+[[metric]]
+context = "test"
+request = '''
+SELECT 1 as value
+FROM dual
+'''
+metricsdesc = { value = "Value." }
+
+[[metric]]
+context = "misspelled"
+request = "SELECT 1 as value FROM dual"
+metricdesc = { value = "Value." }
+ignorezeroresults = true
+`
+
+const misspelledYamlMetrics = `
+metrics:
+- context: "test"
+  request: "SELECT 1 as value FROM dual"
+  metricsdesc:
+    value: "Value."
+- context: "misspelled"
+  request: "SELECT 1 as value FROM dual"
+  metricdesc:
+    value: "Value."
+  ignorezeroresults: true
+`
+
+func TestLoadMetricsFileReportsUnknownKeys(t *testing.T) {
+	dir := t.TempDir()
+	for _, test := range []struct {
+		name    string
+		content string
+		unknown []UnknownKey
+	}{
+		{"metrics.toml", misspelledTomlMetrics, []UnknownKey{
+			{Line: 13, Key: "metric.metricdesc"},
+			{Line: 14, Key: "metric.ignorezeroresults"},
+		}},
+		{"metrics.yaml", misspelledYamlMetrics, []UnknownKey{
+			{Line: 9, Key: "metrics.metricdesc"},
+			{Line: 11, Key: "metrics.ignorezeroresults"},
+		}},
+	} {
+		file := filepath.Join(dir, test.name)
+		assert.NoError(t, os.WriteFile(file, []byte(test.content), 0o644))
+		for i := range test.unknown {
+			test.unknown[i].File = file
+		}
+
+		metrics, unknown, err := LoadMetricsFile(file)
+		assert.NoError(t, err, test.name)
+		assert.Len(t, metrics.Metric, 2, test.name)
+		assert.Equal(t, test.unknown, unknown, test.name)
+	}
+}
+
+func TestStrictMetricsRejectsUnknownKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "metrics.toml")
+	assert.NoError(t, os.WriteFile(file, []byte(misspelledTomlMetrics), 0o644))
+
+	e := &Exporter{logger: log.NewNopLogger(), config: &Config{DefaultMetricsFile: file}}
+	_, err := e.loadMetrics()
+	assert.NoError(t, err)
+
+	e.config.StrictMetrics = true
+	_, err = e.loadMetrics()
+	assert.EqualError(t, err, file+":13: unknown key metric.metricdesc\n"+file+":14: unknown key metric.ignorezeroresults")
+}
+
+func TestLoadMetricsFileAcceptsVersion1(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"metrics.toml": "version = 1\n\n[[metric]]\ncontext = \"test\"\nrequest = \"SELECT 1 as value FROM dual\"\nmetricsdesc = { value = \"Value.\" }\n",
+		"metrics.yaml": "version: 1\nmetrics:\n- context: \"test\"\n  request: \"SELECT 1 as value FROM dual\"\n  metricsdesc:\n    value: \"Value.\"\n",
+	} {
+		file := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+		metrics, unknown, err := LoadMetricsFile(file)
+		assert.NoError(t, err, name)
+		assert.Empty(t, unknown, name)
+		assert.Len(t, metrics.Metric, 1, name)
+	}
+}
@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Target is a database listed in the targets file
+type Target struct {
+	Name           string            `json:"name"`
+	DSN            string            `json:"dsn"`
+	DSNFile        string            `json:"dsn_file"`
+	MaxIdleConns   *int              `json:"max_idle_conns"`
+	MaxOpenConns   *int              `json:"max_open_conns"`
+	QueryTimeout   *int              `json:"query_timeout"`
+	DefaultMetrics string            `json:"default_metrics"`
+	CustomMetrics  []string          `json:"custom_metrics"`
+	Labels         map[string]string `json:"labels"`
+}
+
+// Targets is the content of the targets file
+type Targets struct {
+	Targets []Target `json:"targets"`
+}
+
+// LoadTargets reads the list of databases to scrape from a yaml file
+func LoadTargets(targetsFile string) ([]Target, error) {
+	content, err := os.ReadFile(targetsFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the targets file %s: %w", targetsFile, err)
+	}
+	var targets Targets
+	if err := yaml.Unmarshal(content, &targets); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal the targets file %s: %w", targetsFile, err)
+	}
+
+	names := make(map[string]bool)
+	for i, target := range targets.Targets {
+		if target.Name == "" {
+			return nil, fmt.Errorf("target #%d: name is missing", i+1)
+		}
+		if names[target.Name] {
+			return nil, fmt.Errorf("target %s: duplicated name", target.Name)
+		}
+		names[target.Name] = true
+		if (target.DSN == "") == (target.DSNFile == "") {
+			return nil, fmt.Errorf("target %s: exactly one of dsn and dsn_file must be set", target.Name)
+		}
+		if _, ok := target.Labels["database"]; ok {
+			return nil, fmt.Errorf("target %s: label database is reserved", target.Name)
+		}
+	}
+	return targets.Targets, nil
+}
+
+// Config returns the configuration of the target's Exporter. Settings which
+// are not set for the target are taken from base.
+func (t Target) Config(base Config) (*Config, error) {
+	config := base
+	config.DSN = t.DSN
+	if t.DSNFile != "" {
+		content, err := os.ReadFile(t.DSNFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read dsn file of target %s: %w", t.Name, err)
+		}
+		config.DSN = strings.TrimSpace(string(content))
+	}
+	if config.DSN == "" {
+		return nil, errors.New("empty dsn for target " + t.Name)
+	}
+	if t.MaxIdleConns != nil {
+		config.MaxIdleConns = *t.MaxIdleConns
+	}
+	if t.MaxOpenConns != nil {
+		config.MaxOpenConns = *t.MaxOpenConns
+	}
+	if t.QueryTimeout != nil {
+		config.QueryTimeout = *t.QueryTimeout
+	}
+	if t.DefaultMetrics != "" {
+		config.DefaultMetricsFile = t.DefaultMetrics
+	}
+	if len(t.CustomMetrics) > 0 {
+		config.CustomMetrics = strings.Join(t.CustomMetrics, ",")
+	}
+	return &config, nil
+}
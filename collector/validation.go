@@ -0,0 +1,278 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"sigs.k8s.io/yaml"
+)
+
+// metricTypes are the valid values of metricstype
+var metricTypes = map[string]bool{
+	"gauge":     true,
+	"counter":   true,
+	"histogram": true,
+}
+
+// selectStarRE matches requests selecting all the columns of a table, whose
+// columns can't be known without the database
+var selectStarRE = regexp.MustCompile(`(?i)select\s+(distinct\s+)?([a-z0-9_$#]+\.)?\*`)
+
+// Validate checks the definition of the metric without connecting to the
+// database, and returns all the problems found.
+func (m Metric) Validate() error {
+	var errs []error
+	addError := func(format string, a ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, a...))
+	}
+
+	if strings.TrimSpace(m.Request) == "" {
+		addError("request is missing")
+	}
+	if len(m.MetricsDesc) == 0 {
+		addError("metricsdesc is missing")
+	}
+
+	// Column names returned by the database are lower cased
+	checkColumn := func(kind, column string) {
+		if column != strings.ToLower(column) {
+			addError("%s %s must be lower case", kind, column)
+		}
+		if m.Request != "" && !requestReturns(m.Request, column) {
+			addError("%s %s is not returned by the request", kind, column)
+		}
+	}
+
+	for metric := range m.MetricsDesc {
+		checkColumn("column", m.column(metric))
+		if m.FieldToAppend == "" && !model.IsValidLegacyMetricName(prometheus.BuildFQName(namespace, m.Context, metric)) {
+			addError("invalid metric name %s", prometheus.BuildFQName(namespace, m.Context, metric))
+		}
+	}
+
+	checkedLabels := make(map[string]bool)
+	checkLabels := func(labels []string) {
+		seenLabels := make(map[string]bool)
+		for _, label := range labels {
+			if seenLabels[label] {
+				addError("duplicated label %s", label)
+			}
+			seenLabels[label] = true
+			if checkedLabels[label] {
+				continue
+			}
+			checkedLabels[label] = true
+			checkColumn("label", label)
+			if !model.LabelName(label).IsValidLegacy() {
+				addError("invalid label name %s", label)
+			}
+		}
+	}
+	checkLabels(m.Labels)
+	for _, labels := range m.metricsLabels {
+		checkLabels(labels)
+	}
+
+	if m.FieldToAppend != "" {
+		checkColumn("fieldtoappend", m.FieldToAppend)
+	}
+
+	if err := m.validateTypes(); err != nil {
+		errs = append(errs, err)
+	}
+	for column, metricType := range m.MetricsType {
+		if _, ok := m.MetricsDesc[column]; !ok {
+			addError("metricstype of column %s which is not in metricsdesc", column)
+		}
+		if !strings.EqualFold(metricType, "histogram") || len(m.MetricsBuckets[column]) == 0 {
+			continue
+		}
+		checkColumn("column", "count")
+		for field := range m.MetricsBuckets[column] {
+			checkColumn("bucket column", field)
+		}
+	}
+	for column := range m.MetricsBuckets {
+		if !strings.EqualFold(m.MetricsType[column], "histogram") {
+			addError("metricsbuckets of column %s which is not a histogram", column)
+		}
+	}
+
+	if m.ScrapeInterval != "" {
+		if _, err := time.ParseDuration(m.ScrapeInterval); err != nil {
+			addError("invalid scrapeinterval %s", m.ScrapeInterval)
+		}
+	}
+	if m.QueryTimeout != "" {
+		if _, err := time.ParseDuration(m.QueryTimeout); err != nil {
+			addError("invalid querytimeout %s", m.QueryTimeout)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateTypes checks the types of the metric and the buckets of its
+// histograms, without which the metric cannot be scraped
+func (m Metric) validateTypes() error {
+	var errs []error
+	for column, metricType := range m.MetricsType {
+		if !metricTypes[strings.ToLower(metricType)] {
+			errs = append(errs, fmt.Errorf("unknown metricstype %s for column %s", metricType, column))
+		}
+		if strings.ToLower(metricType) != "histogram" {
+			continue
+		}
+		buckets, ok := m.MetricsBuckets[column]
+		if !ok || len(buckets) == 0 {
+			errs = append(errs, fmt.Errorf("metricsbuckets is missing for histogram column %s", column))
+			continue
+		}
+		for field, le := range buckets {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(le), 64); err != nil {
+				errs = append(errs, fmt.Errorf("invalid bucket limit %s for bucket column %s", le, field))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateMetricTypes checks the types and the histogram buckets of the
+// metrics loaded from the metrics files, which are otherwise not validated
+func validateMetricTypes(metrics Metrics) error {
+	var errs []error
+	for _, m := range metrics.Metric {
+		if err := m.validateTypes(); err != nil {
+			errs = append(errs, fmt.Errorf("metric %s: %w", m.Context, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// requestReturns tells whether the column may be returned by the request,
+// which must then at least mention it
+func requestReturns(request, column string) bool {
+	if selectStarRE.MatchString(request) {
+		return true
+	}
+	columnRE, err := regexp.Compile(`(?i)(^|[^a-z0-9_$#])"?` + regexp.QuoteMeta(column) + `"?($|[^a-z0-9_$#])`)
+	if err != nil {
+		return true
+	}
+	return columnRE.MatchString(request)
+}
+
+// CheckConsistency checks that the metrics sharing the same name have the
+// same labels and help, as Prometheus requires.
+func CheckConsistency(metrics Metrics) error {
+	type definition struct {
+		labels string
+		help   string
+	}
+	var errs []error
+	definitions := make(map[string]definition)
+	for _, m := range metrics.Metric {
+		// Names of metrics using fieldtoappend depend on the data
+		if m.FieldToAppend != "" {
+			continue
+		}
+		for metric, help := range m.MetricsDesc {
+			labels := append([]string{}, m.labels(metric)...)
+			sort.Strings(labels)
+			name := prometheus.BuildFQName(namespace, m.Context, metric)
+			current := definition{labels: strings.Join(labels, ","), help: help}
+			previous, ok := definitions[name]
+			if !ok {
+				definitions[name] = current
+				continue
+			}
+			if previous.labels != current.labels {
+				errs = append(errs, fmt.Errorf("metric %s is defined with different labels [%s] and [%s]", name, previous.labels, current.labels))
+			}
+			if previous.help != current.help {
+				errs = append(errs, fmt.Errorf("metric %s is defined with different help %q and %q", name, previous.help, current.help))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateMetrics validates each metric and their consistency
+func ValidateMetrics(metrics Metrics) error {
+	var errs []error
+	for _, m := range metrics.Metric {
+		if err := m.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("metric %s: %w", m.Context, err))
+		}
+	}
+	if err := CheckConsistency(metrics); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// LoadMetricsFile loads a toml or yaml metrics file, in the legacy or v2
+// format. The keys which don't match any setting, such as misspelled ones,
+// are returned as well.
+func LoadMetricsFile(metricsFile string) (Metrics, []UnknownKey, error) {
+	content, err := os.ReadFile(metricsFile)
+	if err != nil {
+		return Metrics{}, nil, fmt.Errorf("cannot read the metrics config %s: %w", metricsFile, err)
+	}
+	isToml := strings.HasSuffix(metricsFile, "toml")
+	var version metricsVersion
+	if isToml {
+		_, err = toml.Decode(string(content), &version)
+	} else {
+		err = yaml.Unmarshal(content, &version)
+	}
+	if err != nil {
+		return Metrics{}, nil, fmt.Errorf("cannot unmarshal the metrics config %s: %w", metricsFile, err)
+	}
+
+	var metricsV1 metricsV1
+	var metricsV2 MetricsV2
+	var decoded interface{}
+	switch version.Version {
+	case 0, 1:
+		decoded = &metricsV1
+	case 2:
+		decoded = &metricsV2
+	default:
+		return Metrics{}, nil, fmt.Errorf("unsupported version %d of the metrics config %s", version.Version, metricsFile)
+	}
+
+	var unknown []UnknownKey
+	if isToml {
+		md, err := toml.Decode(string(content), decoded)
+		if err != nil {
+			return Metrics{}, nil, fmt.Errorf("cannot read the metrics config %s: %w", metricsFile, err)
+		}
+		unknown = tomlUnknownKeys(metricsFile, string(content), md)
+	} else {
+		if err := yaml.Unmarshal(content, decoded); err != nil {
+			return Metrics{}, nil, fmt.Errorf("cannot unmarshal the metrics config %s: %w", metricsFile, err)
+		}
+		if unknown, err = yamlUnknownKeys(metricsFile, content, decoded); err != nil {
+			return Metrics{}, nil, err
+		}
+	}
+
+	if version.Version < 2 {
+		return Metrics{Metric: metricsV1.Metric}, unknown, nil
+	}
+	metrics, err := metricsV2.Metrics()
+	if err != nil {
+		return Metrics{}, nil, fmt.Errorf("invalid metrics config %s: %w", metricsFile, err)
+	}
+	return metrics, unknown, nil
+}
@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReportsInvalidMetrics(t *testing.T) {
+	metric := Metric{
+		Context:        "test",
+		Labels:         []string{"label_1", "Label_2", "missing"},
+		MetricsDesc:    map[string]string{"value_1": "Value 1.", "value_2": "Value 2."},
+		MetricsType:    map[string]string{"value_1": "gauges", "value_2": "histogram", "value_3": "counter"},
+		Request:        "SELECT 'a' as label_1, 'b' as label_2, 1 as value_1, 2 as value_2 FROM DUAL",
+		ScrapeInterval: "15 minutes",
+	}
+	err := metric.Validate()
+	assert.Error(t, err)
+	for _, expected := range []string{
+		"label Label_2 must be lower case",
+		"label missing is not returned by the request",
+		"unknown metricstype gauges for column value_1",
+		"metricsbuckets is missing for histogram column value_2",
+		"metricstype of column value_3 which is not in metricsdesc",
+		"invalid scrapeinterval 15 minutes",
+	} {
+		assert.Contains(t, err.Error(), expected)
+	}
+}
+
+func TestValidateAcceptsShippedMetrics(t *testing.T) {
+	for _, file := range []string{
+		"../default-metrics.toml",
+		"../default-metrics.yaml",
+		"../default-metrics.legacy-tablespace.toml",
+		"../default-asm-metrics.toml",
+		"../custom-metrics-example/custom-metrics.toml",
+		"../custom-metrics-example/custom-metrics.yaml",
+		"../custom-metrics-example/metric-histogram-example.toml",
+	} {
+		metrics, undecoded, err := LoadMetricsFile(file)
+		assert.NoError(t, err, file)
+		assert.Empty(t, undecoded, file)
+		assert.NoError(t, ValidateMetrics(metrics), file)
+	}
+}
+
+func TestCheckConsistencyReportsConflictingDefinitions(t *testing.T) {
+	metrics := Metrics{Metric: []Metric{
+		{Context: "test", Labels: []string{"a"}, MetricsDesc: map[string]string{"value": "Value."}, Request: "SELECT 1 as value, 'a' as a FROM DUAL"},
+		{Context: "test", Labels: []string{"b"}, MetricsDesc: map[string]string{"value": "Other value."}, Request: "SELECT 1 as value, 'b' as b FROM DUAL"},
+	}}
+	err := CheckConsistency(metrics)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "oracledb_test_value is defined with different labels [a] and [b]")
+	assert.Contains(t, err.Error(), "oracledb_test_value is defined with different help")
+}
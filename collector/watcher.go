@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+// watchDebounce is the delay without further change after which watched
+// files are considered updated, as editors and Kubernetes perform several
+// operations when updating a file
+const watchDebounce = time.Second
+
+// WatchMetricsFiles reloads the metrics of the exporter whenever one of its
+// metrics files changes, until ctx is done.
+func (e *Exporter) WatchMetricsFiles(ctx context.Context) error {
+	return WatchFiles(ctx, e.logger, e.config.MetricsPaths(), func() {
+		e.Reload()
+	})
+}
+
+// WatchFiles calls onChange whenever one of the metrics files designated by
+// paths (files, directories or glob patterns) changes, is added or is
+// removed, until ctx is done. The parent directories of the files are
+// watched rather than the files themselves, so that files replaced by a
+// rename are still watched. This includes Kubernetes ConfigMap volumes,
+// updated by swapping their ..data symlink. The directories which cannot be
+// watched are logged and skipped, as are the directories matching a glob
+// pattern which are created afterwards.
+func WatchFiles(ctx context.Context, logger log.Logger, paths []string, onChange func()) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		for _, dir := range watchedDirs(path) {
+			if dirs[dir] {
+				continue
+			}
+			dirs[dir] = true
+			if err := watcher.Add(dir); err != nil {
+				level.Error(logger).Log("msg", "Unable to watch metrics files", "dir", dir, "error", err)
+				continue
+			}
+			level.Debug(logger).Log("msg", "Watching metrics files", "dir", dir)
+		}
+	}
+
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Chmod) {
+				continue
+			}
+			if !matchesAny(paths, event.Name) && filepath.Base(event.Name) != "..data" {
+				continue
+			}
+			level.Debug(logger).Log("msg", "Metrics file changed", "file", event.Name, "op", event.Op)
+			debounce.Reset(watchDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			level.Error(logger).Log("msg", "Error watching metrics files", "error", err)
+		case <-debounce.C:
+			level.Info(logger).Log("msg", "Metrics files changed, reloading metrics")
+			onChange()
+		}
+	}
+}
+
+// matchesAny tells whether file is one of the metrics files designated by paths
+func matchesAny(paths []string, file string) bool {
+	for _, path := range paths {
+		if matchesMetricsPath(path, file) {
+			return true
+		}
+	}
+	return false
+}
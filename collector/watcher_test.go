@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchFilesDetectsReplacedFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "custom-metrics.toml")
+	assert.NoError(t, os.WriteFile(file, []byte("# v1"), 0600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 10)
+	go WatchFiles(ctx, log.NewNopLogger(), []string{file}, func() {
+		changes <- struct{}{}
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	// Replace the file the way editors and Kubernetes do
+	tmp := filepath.Join(dir, "custom-metrics.toml.tmp")
+	assert.NoError(t, os.WriteFile(tmp, []byte("# v2"), 0600))
+	assert.NoError(t, os.Rename(tmp, file))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "unrelated.toml"), []byte(""), 0600))
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change of the metrics file not detected")
+	}
+	select {
+	case <-changes:
+		t.Fatal("changes not debounced")
+	case <-time.After(2 * watchDebounce):
+	}
+}
+
+func TestWatchFilesMatchingDirectoryPattern(t *testing.T) {
+	dir := t.TempDir()
+	for _, team := range []string{"a", "b"} {
+		assert.NoError(t, os.Mkdir(filepath.Join(dir, team), 0700))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, team, "metrics.toml"), []byte("# v1"), 0600))
+	}
+	pattern := filepath.Join(dir, "*", "metrics.toml")
+	assert.Equal(t, []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}, watchedDirs(pattern))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 10)
+	// The directory which cannot be watched doesn't prevent watching the others
+	missing := filepath.Join(dir, "missing", "metrics.toml")
+	go WatchFiles(ctx, log.NewNopLogger(), []string{missing, pattern}, func() {
+		changes <- struct{}{}
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b", "metrics.toml"), []byte("# v2"), 0600))
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("change of the metrics file not detected")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/iamseth/oracledb_exporter/collector"
+)
+
+// convertConfig converts the metrics file to the v2 format and writes it to
+// out, in toml or yaml depending on format
+func convertConfig(out io.Writer, file, format string) error {
+	metrics, unknown, err := collector.LoadMetricsFile(file)
+	if err != nil {
+		return err
+	}
+	if len(unknown) > 0 {
+		return collector.UnknownKeysError(unknown)
+	}
+	converted := collector.ConvertMetrics(metrics)
+
+	var content []byte
+	if strings.HasSuffix(format, "toml") {
+		var buffer bytes.Buffer
+		if err := toml.NewEncoder(&buffer).Encode(converted); err != nil {
+			return err
+		}
+		content = buffer.Bytes()
+	} else if content, err = yaml.Marshal(converted); err != nil {
+		return err
+	}
+	_, err = out.Write(content)
+	return err
+}
+
+// convertConfigTo converts the metrics file to the v2 format, writing it to
+// the output file if any, or to the standard output
+func convertConfigTo(file, output string) error {
+	if output == "" {
+		return convertConfig(os.Stdout, file, file)
+	}
+	var buffer bytes.Buffer
+	if err := convertConfig(&buffer, file, output); err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, buffer.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("cannot write the converted metrics config %s: %w", output, err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/iamseth/oracledb_exporter/collector"
+)
+
+// doctor checks the request of each metric of the exporter against the
+// database, writing a pass/fail report followed by the grants to run to out.
+// It returns false if any request failed.
+func doctor(out io.Writer, exporter *collector.Exporter) (bool, error) {
+	diagnosis, err := exporter.Diagnose(context.Background())
+	if err != nil {
+		return false, err
+	}
+
+	for _, metric := range diagnosis.Metrics {
+		if metric.Err == nil {
+			fmt.Fprintf(out, "PASS %s\n", metric.Context)
+			continue
+		}
+		fmt.Fprintf(out, "FAIL %s: %v\n", metric.Context, metric.Err)
+		for _, object := range metric.MissingGrants {
+			fmt.Fprintf(out, "     missing grant on %s\n", object)
+		}
+	}
+
+	if grants := diagnosis.Grants(); len(grants) > 0 {
+		fmt.Fprintf(out, "\n-- Grants missing for user %s, to run as SYS\n", diagnosis.User)
+		for _, grant := range grants {
+			fmt.Fprintln(out, grant)
+		}
+	}
+	return !diagnosis.Failed(), nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/iamseth/oracledb_exporter/collector"
+)
+
+// scrapeContext returns the context of a scrape request. Its deadline is
+// derived from the scrape timeout sent by Prometheus, minus an offset
+// leaving time to send the response.
+func scrapeContext(r *http.Request, offset time.Duration) (context.Context, context.CancelFunc) {
+	header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if header == "" {
+		return context.WithCancel(r.Context())
+	}
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > offset {
+		timeout -= offset
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// exporterHandler serves the metrics of the exporters, along with the ones
+// of the default gatherer. Queries still running when the request is
+// cancelled or times out are cancelled as well.
+type exporterHandler struct {
+	exporters     []*collector.Exporter
+	labels        []prometheus.Labels
+	timeoutOffset time.Duration
+	opts          promhttp.HandlerOpts
+}
+
+func (h exporterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := scrapeContext(r, h.timeoutOffset)
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	for i, exporter := range h.exporters {
+		prometheus.WrapRegistererWith(h.labels[i], registry).MustRegister(targetCollector{exporter: exporter, ctx: ctx})
+	}
+	gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, registry}
+	promhttp.HandlerFor(gatherers, h.opts).ServeHTTP(w, r)
+}
+
+// reloadHandler reloads the metrics files when receiving a POST request
+func reloadHandler(reload func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "This endpoint requires a POST request.", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := reload(); err != nil {
+			http.Error(w, "failed to reload metrics: "+err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
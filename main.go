@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/prometheus/client_golang/prometheus/collectors"
 
@@ -18,6 +21,7 @@ import (
 	"github.com/prometheus/common/promlog"
 	"github.com/prometheus/common/promlog/flag"
 
+	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 
 	// Required for debugging
@@ -42,7 +46,7 @@ var (
 	).Default(getEnv("DEFAULT_METRICS", "default-metrics.toml")).String()
 	customMetrics = kingpin.Flag(
 		"custom.metrics",
-		"File that may contain various custom metrics in a toml or yaml format. (env: CUSTOM_METRICS)",
+		"Comma separated list of files, directories or glob patterns of files that may contain various custom metrics in a toml or yaml format. (env: CUSTOM_METRICS)",
 	).Default(getEnv("CUSTOM_METRICS", "")).String()
 	queryTimeout = kingpin.Flag(
 		"query.timeout",
@@ -60,7 +64,65 @@ var (
 		"scrape.interval",
 		"Interval between each scrape. Default is to scrape on collect requests",
 	).Default("0s").Duration()
+	scrapeConcurrency = kingpin.Flag(
+		"scrape.concurrency",
+		"Maximum number of metrics scraped concurrently, 0 for no limit. (env: SCRAPE_CONCURRENCY)",
+	).Default(getEnv("SCRAPE_CONCURRENCY", "0")).Int()
+	scrapeMinInterval = kingpin.Flag(
+		"scrape.min-interval",
+		"Minimum interval between two scrapes of the database, the results of the previous scrape being served in between. (env: SCRAPE_MIN_INTERVAL)",
+	).Default(getEnv("SCRAPE_MIN_INTERVAL", "0s")).Duration()
+	scrapeTimeoutOffset = kingpin.Flag(
+		"scrape.timeout-offset",
+		"Offset to subtract from the scrape timeout sent by Prometheus, leaving time to send the response. (env: SCRAPE_TIMEOUT_OFFSET)",
+	).Default(getEnv("SCRAPE_TIMEOUT_OFFSET", "250ms")).Duration()
+	scrapeMaxTargets = kingpin.Flag(
+		"scrape.max-targets",
+		"Maximum number of databases scraped through /scrape whose connections are kept, those of the least recently scraped one being closed beyond, 0 for no limit. (env: SCRAPE_MAX_TARGETS)",
+	).Default(getEnv("SCRAPE_MAX_TARGETS", "100")).Int()
+	breakerThreshold = kingpin.Flag(
+		"scrape.breaker-threshold",
+		"Number of consecutive failures after which a metric is skipped for an exponentially growing number of scrapes, 0 to disable. (env: SCRAPE_BREAKER_THRESHOLD)",
+	).Default(getEnv("SCRAPE_BREAKER_THRESHOLD", "3")).Int()
+	breakerMaxBackoff = kingpin.Flag(
+		"scrape.breaker-max-backoff",
+		"Maximum number of scrapes a failing metric is skipped for. (env: SCRAPE_BREAKER_MAX_BACKOFF)",
+	).Default(getEnv("SCRAPE_BREAKER_MAX_BACKOFF", "32")).Int()
+	strictMetrics = kingpin.Flag(
+		"metrics.strict",
+		"Fail to load metrics files with unknown keys, such as misspelled ones, instead of only logging them. (env: METRICS_STRICT)",
+	).Default(getEnv("METRICS_STRICT", "false")).Bool()
+	recordFile = kingpin.Flag(
+		"record",
+		"File to record the columns and rows returned by the requests to, to replay them later with --replay. (env: RECORD_FILE)",
+	).Default(getEnv("RECORD_FILE", "")).String()
+	replayFile = kingpin.Flag(
+		"replay",
+		"File of columns and rows recorded with --record, served instead of querying the database. (env: REPLAY_FILE)",
+	).Default(getEnv("REPLAY_FILE", "")).String()
+	configFile = kingpin.Flag(
+		"config.file",
+		"File with the exporter configuration in a yaml format, such as the auth modules of multi-target scrapes. (env: CONFIG_FILE)",
+	).Default(getEnv("CONFIG_FILE", "")).String()
+	targetsFile = kingpin.Flag(
+		"targets.file",
+		"File listing the databases to scrape in a yaml format, instead of the single database given by the DSN. (env: TARGETS_FILE)",
+	).Default(getEnv("TARGETS_FILE", "")).String()
 	toolkitFlags = webflag.AddFlags(kingpin.CommandLine, ":9161")
+
+	serveCommand       = kingpin.Command("serve", "Run the exporter.").Default()
+	checkConfigCommand = kingpin.Command("check-config", "Check the default and custom metrics files without connecting to the database, exiting with a non-zero status on errors.")
+	checkConfigStrict  = checkConfigCommand.Flag("strict", "Report unknown keys of the metrics files as errors rather than warnings.").Default("true").Bool()
+
+	doctorCommand = kingpin.Command("doctor", "Check that the requests of the metrics can be run on the database, and print the grants missing to the user.")
+
+	onceCommand = kingpin.Command("once", "Scrape the database once, write the metrics and exit with a non-zero status if the database is down (1) or if some metrics failed (2).")
+	onceFormat  = onceCommand.Flag("format", "Format of the metrics: text, openmetrics or json.").Default("text").Enum("text", "openmetrics", "json")
+	onceOutput  = onceCommand.Flag("output", "File to write the metrics to, replaced atomically. Default is to write them to the standard output.").Short('o').String()
+
+	convertConfigCommand = kingpin.Command("convert-config", "Convert a metrics file to the v2 format.")
+	convertConfigFile    = convertConfigCommand.Arg("file", "Metrics file to convert, in a toml or yaml format.").Required().String()
+	convertConfigOutput  = convertConfigCommand.Flag("output", "File to write the converted metrics to, in a toml or yaml format depending on its extension. Default is to write them to the standard output in the format of the converted file.").Short('o').String()
 )
 
 func main() {
@@ -68,7 +130,7 @@ func main() {
 	flag.AddFlags(kingpin.CommandLine, promLogConfig)
 	kingpin.HelpFlag.Short('\n')
 	kingpin.Version(version.Print("oracledb_exporter"))
-	kingpin.Parse()
+	command := kingpin.Parse()
 	logger := promlog.New(promLogConfig)
 
 	if dsnFile != nil && *dsnFile != "" {
@@ -80,6 +142,16 @@ func main() {
 		*dsn = string(dsnFileContent)
 	}
 
+	exporterConfig := &collector.ExporterConfig{}
+	if *configFile != "" {
+		var err error
+		exporterConfig, err = collector.LoadExporterConfig(*configFile)
+		if err != nil {
+			level.Error(logger).Log("msg", "Unable to load exporter config", "file", *configFile, "error", err)
+			os.Exit(1)
+		}
+	}
+
 	config := &collector.Config{
 		DSN:                *dsn,
 		MaxOpenConns:       *maxOpenConns,
@@ -87,19 +159,110 @@ func main() {
 		CustomMetrics:      *customMetrics,
 		QueryTimeout:       *queryTimeout,
 		DefaultMetricsFile: *defaultFileMetrics,
+		ScrapeConcurrency:  *scrapeConcurrency,
+		ScrapeMinInterval:  *scrapeMinInterval,
+		BreakerThreshold:   *breakerThreshold,
+		BreakerMaxBackoff:  *breakerMaxBackoff,
+		StrictMetrics:      *strictMetrics,
+		RecordFile:         *recordFile,
+		ReplayFile:         *replayFile,
+	}
+
+	if *recordFile != "" && *replayFile != "" {
+		level.Error(logger).Log("msg", "--record and --replay are mutually exclusive")
+		os.Exit(1)
+	}
+	if *recordFile != "" && *targetsFile != "" {
+		level.Error(logger).Log("msg", "--record records a single database and cannot be used with --targets.file")
+		os.Exit(1)
+	}
+
+	if command == convertConfigCommand.FullCommand() {
+		if err := convertConfigTo(*convertConfigFile, *convertConfigOutput); err != nil {
+			level.Error(logger).Log("msg", "Unable to convert metrics config", "file", *convertConfigFile, "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == checkConfigCommand.FullCommand() {
+		if !checkConfig(os.Stdout, config, *checkConfigStrict) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == doctorCommand.FullCommand() {
+		exporter, err := collector.NewExporter(logger, config)
+		if err != nil {
+			level.Error(logger).Log("msg", "Unable to connect to the database", "error", err)
+			os.Exit(1)
+		}
+		ok, err := doctor(os.Stdout, exporter)
+		if err != nil {
+			level.Error(logger).Log("msg", "Unable to check the metrics", "error", err)
+			os.Exit(1)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+		return
 	}
-	exporter, err := collector.NewExporter(logger, config)
-	if err != nil {
-		level.Error(logger).Log("unable to connect to DB", err)
+
+	var exporters []*collector.Exporter
+	var exportersLabels []prometheus.Labels
+	metricsPaths := config.MetricsPaths()
+	if *targetsFile != "" {
+		targets, err := collector.LoadTargets(*targetsFile)
+		if err != nil {
+			level.Error(logger).Log("msg", "Unable to load targets", "file", *targetsFile, "error", err)
+			os.Exit(1)
+		}
+		for _, target := range targets {
+			targetConfig, err := target.Config(*config)
+			if err != nil {
+				level.Error(logger).Log("msg", "Invalid target", "database", target.Name, "error", err)
+				os.Exit(1)
+			}
+			exporter, err := collector.NewExporter(log.With(logger, "database", target.Name), targetConfig)
+			if err != nil {
+				level.Error(logger).Log("msg", "Unable to create exporter", "database", target.Name, "error", err)
+				os.Exit(1)
+			}
+			labels := prometheus.Labels{"database": target.Name}
+			for name, value := range target.Labels {
+				labels[name] = value
+			}
+			exporters = append(exporters, exporter)
+			exportersLabels = append(exportersLabels, labels)
+			metricsPaths = append(metricsPaths, targetConfig.MetricsPaths()...)
+		}
+	} else {
+		exporter, err := collector.NewExporter(logger, config)
+		if err != nil {
+			level.Error(logger).Log("unable to connect to DB", err)
+		}
+		exporters = append(exporters, exporter)
+		exportersLabels = append(exportersLabels, nil)
+	}
+
+	if command == onceCommand.FullCommand() {
+		status, err := scrapeOnce(logger, exporters, exportersLabels, *onceFormat, *onceOutput)
+		if err != nil {
+			level.Error(logger).Log("msg", "Unable to write metrics", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(status)
 	}
 
 	if *scrapeInterval != 0 {
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
-		go exporter.RunScheduledScrapes(ctx, *scrapeInterval)
+		for _, exporter := range exporters {
+			go exporter.RunScheduledScrapes(ctx, *scrapeInterval)
+		}
 	}
 
-	prometheus.MustRegister(exporter)
 	prometheus.MustRegister(collectors.NewBuildInfoCollector())
 
 	level.Info(logger).Log("msg", "Starting oracledb_exporter", "version", version.Info())
@@ -109,7 +272,47 @@ func main() {
 	opts := promhttp.HandlerOpts{
 		ErrorHandling: promhttp.ContinueOnError,
 	}
-	http.Handle(*metricPath, promhttp.HandlerFor(prometheus.DefaultGatherer, opts))
+	http.Handle(*metricPath, exporterHandler{
+		exporters:     exporters,
+		labels:        exportersLabels,
+		timeoutOffset: *scrapeTimeoutOffset,
+		opts:          opts,
+	})
+	targets := newTargetExporters(logger, *config, exporterConfig.AuthModules, *scrapeTimeoutOffset, *scrapeMaxTargets)
+	http.HandleFunc("/scrape", targets.scrapeHandler)
+
+	// reload reloads the metrics files of every exporter, those of failing
+	// exporters being kept
+	reload := func() error {
+		var errs []error
+		for _, exporter := range append(targets.list(), exporters...) {
+			if err := exporter.Reload(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+		level.Info(logger).Log("msg", "Metrics reloaded")
+		return nil
+	}
+	http.Handle("/-/reload", reloadHandler(reload))
+	go func() {
+		err := collector.WatchFiles(context.Background(), logger, metricsPaths, func() {
+			reload()
+		})
+		if err != nil {
+			level.Error(logger).Log("msg", "Unable to watch metrics files", "error", err)
+		}
+	}()
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		for range hup {
+			reload()
+		}
+	}()
+
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("<html><head><title>Oracle DB Exporter " + Version + "</title></head><body><h1>Oracle DB Exporter " + Version + "</h1><p><a href='" + *metricPath + "'>Metrics</a></p></body></html>"))
 	})
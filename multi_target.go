@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/iamseth/oracledb_exporter/collector"
+)
+
+// targetCollector exposes the metrics of a target exporter without
+// describing them, so that registering it on a fresh registry does not
+// trigger an additional scrape of the database. The scrape is cancelled
+// when ctx is done.
+type targetCollector struct {
+	exporter *collector.Exporter
+	ctx      context.Context
+}
+
+// Describe implements prometheus.Collector.
+func (c targetCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c targetCollector) Collect(ch chan<- prometheus.Metric) {
+	c.exporter.CollectContext(c.ctx, ch)
+}
+
+// targetExporter is the exporter of a scraped target
+type targetExporter struct {
+	exporter *collector.Exporter
+	lastUsed time.Time
+}
+
+// targetExporters keeps one Exporter per scraped target, so that connection
+// pools and custom metrics are reused between scrapes of the same database.
+type targetExporters struct {
+	mu          sync.Mutex
+	logger      log.Logger
+	config      collector.Config
+	authModules map[string]collector.AuthModule
+	exporters   map[string]*targetExporter
+	// timeoutOffset is subtracted from the scrape timeout sent by Prometheus
+	timeoutOffset time.Duration
+	// maxTargets is the number of exporters kept, the least recently used
+	// one being closed to scrape a new target, 0 meaning no limit
+	maxTargets int
+}
+
+func newTargetExporters(logger log.Logger, config collector.Config, authModules map[string]collector.AuthModule, timeoutOffset time.Duration, maxTargets int) *targetExporters {
+	// The results of the targets are not recorded, as they would overwrite
+	// each other in the same file
+	config.RecordFile = ""
+	return &targetExporters{
+		logger:        logger,
+		config:        config,
+		authModules:   authModules,
+		exporters:     make(map[string]*targetExporter),
+		timeoutOffset: timeoutOffset,
+		maxTargets:    maxTargets,
+	}
+}
+
+// get returns the Exporter of the given target, creating it on first use,
+// in which case the least recently used exporter is closed if there are
+// already maxTargets exporters. When an auth module is given, its
+// credentials are used to connect to the target, otherwise the target is
+// expected to be a complete DSN. Once auth modules are configured, an auth
+// module must be given, so that credentials are not sent in targets.
+func (t *targetExporters) get(target, authModule string) (*collector.Exporter, error) {
+	exporter, evicted, err := t.getOrCreate(target, authModule)
+	// The evicted exporter is closed once its running scrape, if any, is
+	// done, without blocking the scrapes of the other targets
+	if evicted != nil {
+		if err := evicted.Close(); err != nil {
+			level.Error(t.logger).Log("msg", "Unable to close the exporter of an evicted target", "error", err)
+		}
+	}
+	return exporter, err
+}
+
+func (t *targetExporters) getOrCreate(target, authModule string) (*collector.Exporter, *collector.Exporter, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := authModule + "/" + target
+	if cached, ok := t.exporters[key]; ok {
+		cached.lastUsed = time.Now()
+		return cached.exporter, nil, nil
+	}
+	config := t.config
+	config.DSN = target
+	if authModule == "" && len(t.authModules) > 0 {
+		return nil, nil, errors.New("'auth_module' parameter must be specified")
+	}
+	if authModule != "" {
+		module, ok := t.authModules[authModule]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown auth module %s", authModule)
+		}
+		dsn, err := module.DSN(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		config.DSN = dsn
+	}
+	exporter, err := collector.NewExporter(t.logger, &config)
+	if err != nil {
+		return nil, nil, errors.New("unable to connect to target")
+	}
+
+	var evicted *collector.Exporter
+	if t.maxTargets > 0 && len(t.exporters) >= t.maxTargets {
+		var oldest string
+		for key, cached := range t.exporters {
+			if oldest == "" || cached.lastUsed.Before(t.exporters[oldest].lastUsed) {
+				oldest = key
+			}
+		}
+		evicted = t.exporters[oldest].exporter
+		delete(t.exporters, oldest)
+	}
+	t.exporters[key] = &targetExporter{exporter: exporter, lastUsed: time.Now()}
+	return exporter, evicted, nil
+}
+
+// list returns the exporters created so far
+func (t *targetExporters) list() []*collector.Exporter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	exporters := make([]*collector.Exporter, 0, len(t.exporters))
+	for _, cached := range t.exporters {
+		exporters = append(exporters, cached.exporter)
+	}
+	return exporters
+}
+
+// scrapeHandler serves the metrics of the database given by the target
+// parameter, following the multi-target exporter pattern.
+func (t *targetExporters) scrapeHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "'target' parameter must be specified", http.StatusBadRequest)
+		return
+	}
+
+	authModule := r.URL.Query().Get("auth_module")
+	exporter, err := t.get(target, authModule)
+	if err != nil {
+		level.Error(t.logger).Log("msg", "Unable to create exporter for target", "auth_module", authModule, "error", err)
+		http.Error(w, "unable to create exporter for target: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := scrapeContext(r, t.timeoutOffset)
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(targetCollector{exporter: exporter, ctx: ctx})
+	opts := promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError,
+	}
+	promhttp.HandlerFor(registry, opts).ServeHTTP(w, r)
+}
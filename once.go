@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/iamseth/oracledb_exporter/collector"
+)
+
+// Exit statuses of the once command when a database is down, and when the
+// scrape of some metrics failed
+const (
+	onceExitDown   = 1
+	onceExitFailed = 2
+)
+
+// scrapeOnce scrapes the exporters once and writes their metrics in the given
+// format to the output file, replaced atomically, or to the standard output.
+// It returns the exit status telling whether the scrape succeeded. The
+// metrics which could be gathered are written even if others were
+// inconsistent, which is logged and reported as a failed scrape.
+func scrapeOnce(logger log.Logger, exporters []*collector.Exporter, labels []prometheus.Labels, format, output string) (int, error) {
+	registry := prometheus.NewRegistry()
+	for i, exporter := range exporters {
+		prometheus.WrapRegistererWith(labels[i], registry).MustRegister(targetCollector{exporter: exporter, ctx: context.Background()})
+	}
+	families, gatherErr := registry.Gather()
+	if gatherErr != nil {
+		level.Error(logger).Log("msg", "Unable to gather some metrics", "error", gatherErr)
+	}
+
+	var buffer bytes.Buffer
+	if err := writeMetricFamilies(&buffer, families, format); err != nil {
+		return 0, err
+	}
+	var err error
+	if output == "" {
+		_, err = os.Stdout.Write(buffer.Bytes())
+	} else {
+		if err = collector.WriteFileAtomically(output, buffer.Bytes()); err != nil {
+			err = fmt.Errorf("cannot replace %s: %w", output, err)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	status := scrapeStatus(families)
+	if gatherErr != nil && status == 0 {
+		status = onceExitFailed
+	}
+	return status, nil
+}
+
+// scrapeStatus returns the exit status of the once command from the metrics
+// of the exporters
+func scrapeStatus(families []*dto.MetricFamily) int {
+	status := 0
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			switch {
+			case family.GetName() == "oracledb_up" && metric.GetGauge().GetValue() == 0:
+				return onceExitDown
+			case family.GetName() == "oracledb_exporter_scrape_success" && metric.GetGauge().GetValue() == 0:
+				status = onceExitFailed
+			}
+		}
+	}
+	return status
+}
+
+// writeMetricFamilies writes the metrics in the Prometheus text format, the
+// OpenMetrics format or json
+func writeMetricFamilies(w io.Writer, families []*dto.MetricFamily, format string) error {
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(jsonMetricFamilies(families))
+	}
+
+	expFormat := expfmt.NewFormat(expfmt.TypeTextPlain)
+	if format == "openmetrics" {
+		expFormat = expfmt.NewFormat(expfmt.TypeOpenMetrics)
+	}
+	encoder := expfmt.NewEncoder(w, expFormat)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return err
+		}
+	}
+	if closer, ok := encoder.(expfmt.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// jsonMetricFamily is a metric family written in json. Like in the responses
+// of the Prometheus API, values are strings so that NaN and infinite values
+// can be written.
+type jsonMetricFamily struct {
+	Name    string       `json:"name"`
+	Help    string       `json:"help"`
+	Type    string       `json:"type"`
+	Metrics []jsonMetric `json:"metrics"`
+}
+
+type jsonMetric struct {
+	Labels  map[string]string `json:"labels,omitempty"`
+	Value   string            `json:"value,omitempty"`
+	Count   string            `json:"count,omitempty"`
+	Sum     string            `json:"sum,omitempty"`
+	Buckets map[string]string `json:"buckets,omitempty"`
+}
+
+func jsonMetricFamilies(families []*dto.MetricFamily) []jsonMetricFamily {
+	formatFloat := func(value float64) string {
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+	converted := make([]jsonMetricFamily, 0, len(families))
+	for _, family := range families {
+		jsonFamily := jsonMetricFamily{
+			Name:    family.GetName(),
+			Help:    family.GetHelp(),
+			Type:    family.GetType().String(),
+			Metrics: []jsonMetric{},
+		}
+		for _, metric := range family.GetMetric() {
+			var m jsonMetric
+			if len(metric.GetLabel()) > 0 {
+				m.Labels = make(map[string]string)
+				for _, label := range metric.GetLabel() {
+					m.Labels[label.GetName()] = label.GetValue()
+				}
+			}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				m.Value = formatFloat(metric.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				m.Value = formatFloat(metric.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				m.Value = formatFloat(metric.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				histogram := metric.GetHistogram()
+				m.Count = strconv.FormatUint(histogram.GetSampleCount(), 10)
+				m.Sum = formatFloat(histogram.GetSampleSum())
+				m.Buckets = make(map[string]string)
+				for _, bucket := range histogram.GetBucket() {
+					m.Buckets[formatFloat(bucket.GetUpperBound())] = strconv.FormatUint(bucket.GetCumulativeCount(), 10)
+				}
+			}
+			jsonFamily.Metrics = append(jsonFamily.Metrics, m)
+		}
+		converted = append(converted, jsonFamily)
+	}
+	return converted
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/iamseth/oracledb_exporter/collector"
+)
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
+// gaugeFamily returns a family of gauges with the given values, labelled by
+// context
+func gaugeFamily(name string, values map[string]float64) *dto.MetricFamily {
+	family := &dto.MetricFamily{Name: ptr(name), Help: ptr("Help."), Type: dto.MetricType_GAUGE.Enum()}
+	for context, value := range values {
+		metric := &dto.Metric{Gauge: &dto.Gauge{Value: ptr(value)}}
+		if context != "" {
+			metric.Label = []*dto.LabelPair{{Name: ptr("context"), Value: ptr(context)}}
+		}
+		family.Metric = append(family.Metric, metric)
+	}
+	return family
+}
+
+func TestScrapeStatus(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		up       float64
+		success  map[string]float64
+		expected int
+	}{
+		{"succeeded", 1, map[string]float64{"a": 1, "b": 1}, 0},
+		{"failed", 1, map[string]float64{"a": 1, "b": 0}, onceExitFailed},
+		{"down", 0, map[string]float64{"a": 0}, onceExitDown},
+	} {
+		families := []*dto.MetricFamily{
+			gaugeFamily("oracledb_exporter_scrape_success", test.success),
+			gaugeFamily("oracledb_up", map[string]float64{"": test.up}),
+		}
+		assert.Equal(t, test.expected, scrapeStatus(families), test.name)
+	}
+}
+
+func TestJSONMetricFamilies(t *testing.T) {
+	histogram := &dto.MetricFamily{
+		Name: ptr("oracledb_duration_seconds"),
+		Help: ptr("Duration."),
+		Type: dto.MetricType_HISTOGRAM.Enum(),
+		Metric: []*dto.Metric{{Histogram: &dto.Histogram{
+			SampleCount: ptr[uint64](3),
+			SampleSum:   ptr(4.5),
+			Bucket: []*dto.Bucket{
+				{UpperBound: ptr[float64](1), CumulativeCount: ptr[uint64](1)},
+				{UpperBound: ptr[float64](10), CumulativeCount: ptr[uint64](3)},
+			},
+		}}},
+	}
+	converted := jsonMetricFamilies([]*dto.MetricFamily{
+		gaugeFamily("oracledb_exporter_scrape_success", map[string]float64{"a": 1}),
+		histogram,
+	})
+	assert.Equal(t, []jsonMetricFamily{{
+		Name:    "oracledb_exporter_scrape_success",
+		Help:    "Help.",
+		Type:    "GAUGE",
+		Metrics: []jsonMetric{{Labels: map[string]string{"context": "a"}, Value: "1"}},
+	}, {
+		Name:    "oracledb_duration_seconds",
+		Help:    "Duration.",
+		Type:    "HISTOGRAM",
+		Metrics: []jsonMetric{{Count: "3", Sum: "4.5", Buckets: map[string]string{"1": "1", "10": "3"}}},
+	}}, converted)
+}
+
+func TestScrapeOnceWritesGatheredMetrics(t *testing.T) {
+	dir := t.TempDir()
+	metricsFile := filepath.Join(dir, "metrics.toml")
+	assert.NoError(t, os.WriteFile(metricsFile, []byte(`
+[[metric]]
+context = "process"
+metricsdesc = { count = "Number of processes." }
+request = "SELECT COUNT(*) as count FROM v$process"
+`), 0o644))
+	fixture := collector.Fixture{Request: "SELECT COUNT(*) as count FROM v$process", Columns: []string{"COUNT"}, Rows: [][]string{{"85"}}}
+
+	// Both exporters have the same labels, so that their metrics collide
+	var exporters []*collector.Exporter
+	for i := 0; i < 2; i++ {
+		exporter, err := collector.New(context.Background(),
+			collector.WithDefaultMetrics(metricsFile),
+			collector.WithBackend(collector.NewFakeBackend(fixture)))
+		assert.NoError(t, err)
+		defer exporter.Close()
+		exporters = append(exporters, exporter)
+	}
+	output := filepath.Join(dir, "metrics.prom")
+	status, err := scrapeOnce(log.NewNopLogger(), exporters, []prometheus.Labels{{}, {}}, "", output)
+	assert.NoError(t, err)
+	assert.Equal(t, onceExitFailed, status)
+	content, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "oracledb_process_count 85\n")
+}